@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/config"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/importer"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/repository"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/storage"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/validators"
+)
+
+// runImport imports subscriptions from a CSV or NDJSON file like
+// POST /api/v1/subscriptions/import and prints the report as JSON:
+//
+//	subscriptions-app import [-format csv|ndjson] [-dry-run] [-actor name] file
+//
+// The file "-" is read from the standard input, which needs -format.
+func runImport(cfg *config.Config, args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	formatName := flags.String("format", "", "format of the file, csv or ndjson; told by the file extension by default")
+	dryRun := flags.Bool("dry-run", false, "check the rows without saving any")
+	actor := flags.String("actor", models.SystemActor, "actor the import is recorded under in the audit log")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: subscriptions-app import [flags] file")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	name := flags.Arg(0)
+
+	var format importer.Format
+	var err error
+	if *formatName != "" {
+		format, err = importer.ParseFormat(*formatName)
+	} else {
+		format, err = importer.FormatOf(name)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	var file io.ReadCloser = os.Stdin
+	if name != "-" {
+		if file, err = os.Open(name); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	defer file.Close()
+	rows, err := importer.NewReader(file, format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		validators.RegisterValidators(v)
+	}
+	if err := storage.InitDB(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer storage.DB.Close()
+	subscriptions := repository.NewPostgresSubscriptionRepository(storage.DB, cfg.QueryTimeout)
+	im := importer.New(subscriptions, repository.NewPostgresServiceRepository(storage.DB, cfg.QueryTimeout))
+	// an interrupt stops the import after the batches saved so far
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	report, err := im.Import(ctx, rows, importer.Options{
+		DryRun: *dryRun,
+		Audit:  models.AuditInfo{Actor: *actor, RequestId: uuid.NewString()},
+	})
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	if report != nil {
+		out.Encode(report)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "import failed:", err)
+		return 1
+	}
+	return 0
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -10,25 +12,61 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/config"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/jobs"
 	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/logger"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/repository"
 	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/routes"
 	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/storage"
 )
 
+// version is the release of the service, set at build time with
+// -ldflags "-X main.version=...".
+var version = "dev"
+
 // @title Users Online Subscriptions Data Aggregator API
 // @version 1.0
-// @description API documentation for Users Online Subscriptions Data Aggregator
+// @description API documentation for Users Online Subscriptions Data Aggregator. Routes outside /api/v1 are deprecated aliases and answer with Deprecation, Sunset and Link headers
 // @BasePath /
+//
+// Run without arguments it serves the API; "import" imports subscriptions from
+// a file instead, see runImport, and "migrate" manages the schema of the
+// database, see runMigrate.
 func main() {
 	cfg := config.MustLoad()
 	logger.InitLogger(cfg.Env)
-	storage.InitDB(cfg)
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(cfg, os.Args[1], os.Args[2:]))
+	}
+	if err := storage.InitDB(cfg); err != nil {
+		logger.Log.Error("could not start", "err", err)
+		os.Exit(1)
+	}
+	schemaVersion, err := storage.LatestMigration(cfg)
+	if err != nil {
+		logger.Log.Error("could not start", "err", err)
+		os.Exit(1)
+	}
+	health := &routes.Health{Version: version, SchemaVersion: schemaVersion}
 	server := gin.Default()
-	routes.RegisterRoutes(server)
+	subscriptions := repository.NewPostgresSubscriptionRepository(storage.DB, cfg.QueryTimeout)
+	routes.RegisterRoutes(server, routes.Repositories{
+		Subscriptions: subscriptions,
+		Rates:         repository.NewPostgresRateRepository(storage.DB, cfg.QueryTimeout),
+		Services:      repository.NewPostgresServiceRepository(storage.DB, cfg.QueryTimeout),
+		Schema:        repository.NewPostgresSchemaRepository(storage.DB, cfg.QueryTimeout),
+	}, health)
+	// requests still running when the shutdown grace period ends are cancelled
+	// through their base context, which stops their queries too
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
 	srv := &http.Server{
-		Addr:    cfg.ServerConfig.Url,
-		Handler: server,
+		Addr:        cfg.ServerConfig.Url,
+		Handler:     server,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
 	}
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go jobs.RunPurge(jobsCtx, subscriptions, cfg.PurgeConfig)
 	go func() {
 		logger.Log.Info("server started", "addr", srv.Addr)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -39,10 +77,13 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	logger.Log.Info("shutdown signal received")
+	health.ShutDown()
+	stopJobs()
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Log.Error("server forced to shutdown", "err", err)
+		cancelRequests()
 	} else {
 		logger.Log.Info("server stopped gracefully")
 	}
@@ -54,3 +95,15 @@ func main() {
 		}
 	}
 }
+
+// runCommand runs the subcommand name and returns the exit code of the process.
+func runCommand(cfg *config.Config, name string, args []string) int {
+	switch name {
+	case "import":
+		return runImport(cfg, args)
+	case "migrate":
+		return runMigrate(cfg, args)
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q, expected import or migrate\n", name)
+	return 2
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/config"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/storage"
+)
+
+const migrateUsage = `usage: subscriptions-app migrate command
+
+commands:
+  up [N]      apply all pending migrations, or the next N
+  down [N]    undo the last N migrations, 1 by default
+  goto V      migrate up or down to version V
+  version     print the version of the database
+  force V     set the version without migrating, to clear a dirty state
+              after a failed migration has been repaired by hand; -1 clears it`
+
+// runMigrate manages the schema of the database with the migrations shipped
+// with the service, see migrateUsage, and prints the version it ends at.
+func runMigrate(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	command, args := args[0], args[1:]
+	arg, ok := migrateArg(command, args)
+	if !ok {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	m, err := storage.NewMigrate(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer m.Close()
+	// an interrupt stops once the running migration is complete
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		m.GracefulStop <- true
+	}()
+
+	switch command {
+	case "up":
+		if arg > 0 {
+			err = m.Steps(arg)
+		} else {
+			err = m.Up()
+		}
+	case "down":
+		err = m.Steps(-arg)
+	case "goto":
+		err = m.Migrate(uint(arg))
+	case "force":
+		err = m.Force(arg)
+	}
+	if errors.Is(err, migrate.ErrNoChange) {
+		fmt.Println("no change")
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, "migrate failed:", err)
+		printVersion(m)
+		return 1
+	}
+	if !printVersion(m) {
+		return 1
+	}
+	return 0
+}
+
+// migrateArg checks the arguments of command and returns its number: the
+// count of up and down, 0 for all pending ones and 1 by default respectively,
+// and the version of goto and force.
+func migrateArg(command string, args []string) (int, bool) {
+	if len(args) > 1 {
+		return 0, false
+	}
+	var n int
+	if len(args) == 1 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil {
+			return 0, false
+		}
+	}
+	switch command {
+	case "up":
+		return n, n >= 0
+	case "down":
+		if len(args) == 0 {
+			n = 1
+		}
+		return n, n > 0
+	case "goto":
+		return n, len(args) == 1 && n > 0
+	case "force":
+		return n, len(args) == 1 && n >= -1
+	case "version":
+		return 0, len(args) == 0
+	}
+	return 0, false
+}
+
+// printVersion prints the version of the database and reports whether it
+// could be read.
+func printVersion(m *migrate.Migrate) bool {
+	version, dirty, err := m.Version()
+	switch {
+	case errors.Is(err, migrate.ErrNilVersion):
+		fmt.Println("no migration applied")
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "could not read the version:", err)
+		return false
+	case dirty:
+		fmt.Printf("version %d (dirty)\n", version)
+	default:
+		fmt.Printf("version %d\n", version)
+	}
+	return true
+}
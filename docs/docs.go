@@ -15,9 +15,68 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/subscription": {
+        "/api/v1/admin/rates": {
+            "get": {
+                "description": "Get the monthly currency rates matching the given filters",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Rates"
+                ],
+                "summary": "Get currency rates",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Base currency",
+                        "name": "base",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Quote currency",
+                        "name": "quote",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest month (MM-YYYY)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Latest month (MM-YYYY)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.CurrencyRate"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            },
             "put": {
-                "description": "Update an existing subscription by ID",
+                "description": "Create or replace the rate of a currency pair for a month",
                 "consumes": [
                     "application/json"
                 ],
@@ -25,17 +84,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Subscription"
+                    "Rates"
                 ],
-                "summary": "Update subscription",
+                "summary": "Save currency rate",
                 "parameters": [
                     {
-                        "description": "Subscription data",
-                        "name": "subscription",
+                        "description": "Currency rate",
+                        "name": "rate",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.UpdateSubscription"
+                            "$ref": "#/definitions/models.CurrencyRate"
                         }
                     }
                 ],
@@ -52,116 +111,129 @@ const docTemplate = `{
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
-                        }
-                    },
-                    "404": {
-                        "description": "Not Found",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/api/v1/admin/rates/import": {
             "post": {
-                "description": "Create a new subscription entry",
+                "description": "Create or replace currency rates from CSV with the header \"base,quote,month,rate\", months formatted as MM-YYYY. The CSV is sent as the request body or as the \"file\" field of a multipart form. Nothing is saved if any line is invalid.",
                 "consumes": [
-                    "application/json"
+                    "text/csv",
+                    "multipart/form-data"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Subscription"
+                    "Rates"
                 ],
-                "summary": "Create new subscription",
+                "summary": "Import currency rates",
                 "parameters": [
                     {
-                        "description": "Subscription data",
-                        "name": "subscription",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.Subscription"
-                        }
+                        "type": "file",
+                        "description": "CSV file",
+                        "name": "file",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
                             "type": "object",
-                            "additionalProperties": true
+                            "additionalProperties": {
+                                "type": "integer"
+                            }
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     }
                 }
             }
         },
-        "/subscription/all": {
-            "get": {
-                "description": "Get a list of all subscriptions",
+        "/api/v1/admin/rates/{base}/{quote}/{month}": {
+            "delete": {
+                "description": "Delete the rate of a currency pair for a month",
                 "tags": [
-                    "Subscription"
+                    "Rates"
+                ],
+                "summary": "Delete currency rate",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Base currency",
+                        "name": "base",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Quote currency",
+                        "name": "quote",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Month (MM-YYYY)",
+                        "name": "month",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Get all subscriptions",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.Subscription"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
                             }
                         }
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     }
                 }
             }
         },
-        "/subscription/invoice": {
+        "/api/v1/invoices": {
             "post": {
-                "description": "Calculate the total cost of subscriptions for a given user and period",
+                "description": "Calculate the total cost of subscriptions for a period, optionally filtered by user and service and grouped by service, user or month",
                 "consumes": [
                     "application/json"
                 ],
@@ -187,203 +259,2189 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "integer",
-                                "format": "int32"
-                            }
+                            "$ref": "#/definitions/models.SubscriptionInvoice"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     }
                 }
             }
         },
-        "/subscription/{id}": {
-            "get": {
-                "description": "Get a single subscription by its ID",
+        "/api/v1/invoices/details": {
+            "post": {
+                "description": "Calculate the cost of subscriptions for a period, broken down per subscription and calendar month",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
                     "Subscription"
                 ],
-                "summary": "Get subscription by ID",
+                "summary": "Get subscriptions invoice details",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Subscription ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Invoice Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionInvoiceRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Subscription"
+                            "$ref": "#/definitions/models.SubscriptionInvoiceDetails"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "422": {
+                        "description": "Unprocessable Entity",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Delete a subscription by its ID",
+            }
+        },
+        "/api/v1/invoices/export": {
+            "post": {
+                "description": "Download the months billed for the invoice, one line per subscription and month, as CSV, NDJSON or XLSX. With a currency the amounts are converted into it, each at the rate of its month. The file is streamed as the months are read, so a failure after the first line cuts it short. CSV and XLSX have the columns subscription_id, service_id, service_name, user_id, month, amount and currency",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
                 "tags": [
                     "Subscription"
                 ],
-                "summary": "Delete subscription",
+                "summary": "Export invoice breakdown",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Subscription ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "enum": [
+                            "csv",
+                            "ndjson",
+                            "xlsx"
+                        ],
+                        "type": "string",
+                        "description": "Format of the file (default csv)",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Invoice Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionInvoiceRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.InvoiceLine"
                             }
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "422": {
+                        "description": "Unprocessable Entity",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "models.Subscription": {
-            "description": "A subscription that a user has to a service",
-            "type": "object",
-            "required": [
-                "monthly_price",
-                "service_name",
-                "start_date",
-                "user_id"
-            ],
-            "properties": {
-                "end_date": {
-                    "description": "End date of the subscription\nexample: \"06-2006\"",
-                    "type": "string"
-                },
-                "id": {
-                    "description": "ID of the subscription\nexample: 1",
-                    "type": "integer"
-                },
-                "monthly_price": {
-                    "description": "Monthly price of the subscription\nexample: 100",
-                    "type": "integer"
-                },
-                "service_name": {
-                    "description": "Name of the service\nexample: \"Netflix\"",
-                    "type": "string"
-                },
-                "start_date": {
-                    "description": "Start date of the subscription\nexample: \"06-2006\"",
-                    "type": "string"
-                },
-                "user_id": {
-                    "description": "ID of the user who owns the subscription\nexample: \"f47ac10b-58cc-4372-a567-0e02b2c3d479\"",
-                    "type": "string"
+        },
+        "/api/v1/services": {
+            "get": {
+                "description": "Get all services of the catalogue ordered by name",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Services"
+                ],
+                "summary": "Get services",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Service"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Add a service to the catalogue. Its name and aliases must not match those of another service, ignoring case and extra whitespace",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Services"
+                ],
+                "summary": "Create service",
+                "parameters": [
+                    {
+                        "description": "Service data",
+                        "name": "service",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Service"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/services/{id}": {
+            "get": {
+                "description": "Get a single service of the catalogue by its ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Services"
+                ],
+                "summary": "Get service by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Service ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Service"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace a service of the catalogue. Renaming it renames its subscriptions as well, which changes their versions and is recorded in their history",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Services"
+                ],
+                "summary": "Update service",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Service ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Service data",
+                        "name": "service",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Service"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove a service from the catalogue. Services still used by subscriptions cannot be deleted",
+                "tags": [
+                    "Services"
+                ],
+                "summary": "Delete service",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Service ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions": {
+            "get": {
+                "description": "Get a page of subscriptions matching the given filters",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "Get all subscriptions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Service ID",
+                        "name": "service_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Service name or alias",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency of the monthly price",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimal monthly price in minor units",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximal monthly price in minor units",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Month the subscription is active in (MM-YYYY)",
+                        "name": "active_at",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest start date (MM-YYYY)",
+                        "name": "start_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Latest start date (MM-YYYY)",
+                        "name": "start_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest end date (MM-YYYY)",
+                        "name": "end_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Latest end date (MM-YYYY)",
+                        "name": "end_to",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "id",
+                            "service_name",
+                            "monthly_price",
+                            "start_date",
+                            "end_date"
+                        ],
+                        "type": "string",
+                        "description": "Sort column",
+                        "name": "sort_by",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Sort direction",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page, valid with the same sort_by",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (1-100, default 20)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of subscriptions to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Whether deleted subscriptions are listed too",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create a new subscription entry",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "Create new subscription",
+                "parameters": [
+                    {
+                        "description": "Subscription data",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Subscription"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/bulk": {
+            "put": {
+                "description": "Update many subscriptions like PUT /api/v1/subscriptions/{id} in one transaction, from a JSON array or NDJSON of updates carrying their id. Updates are applied in order, so a later update of the same subscription sees the earlier ones. A version in an update is checked like If-Match. Atomic and per-item modes work as in the bulk creation",
+                "consumes": [
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "Update subscriptions in bulk",
+                "parameters": [
+                    {
+                        "description": "Updates, at most 10000",
+                        "name": "subscriptions",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.UpdateSubscription"
+                            }
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Whether any failed item fails the whole batch (default true)",
+                        "name": "atomic",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.BulkResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create many subscriptions in one transaction from a JSON array or from NDJSON (application/x-ndjson), one subscription per line. By default the batch is atomic: when any item is invalid nothing is saved and the failed items are listed in a 422 problem, keyed by their index. With atomic=false the valid items are saved and the outcome of every item is returned",
+                "consumes": [
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "Create subscriptions in bulk",
+                "parameters": [
+                    {
+                        "description": "Subscriptions to create, at most 10000",
+                        "name": "subscriptions",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Subscription"
+                            }
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Whether any failed item fails the whole batch (default true)",
+                        "name": "atomic",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Outcome of a batch that is not atomic",
+                        "schema": {
+                            "$ref": "#/definitions/routes.BulkResult"
+                        }
+                    },
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/routes.BulkResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/bulk/delete": {
+            "post": {
+                "description": "Delete many subscriptions like DELETE /api/v1/subscriptions/{id} in one transaction, from a JSON array or NDJSON of ids. Atomic and per-item modes work as in the bulk creation",
+                "consumes": [
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "Delete subscriptions in bulk",
+                "parameters": [
+                    {
+                        "description": "IDs of the subscriptions to delete, at most 10000",
+                        "name": "ids",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "integer"
+                            }
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Whether any failed item fails the whole batch (default true)",
+                        "name": "atomic",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.BulkResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/export": {
+            "get": {
+                "description": "Download all subscriptions matching the filters of the listing, sorted like it, as CSV, NDJSON or XLSX. The pagination parameters are ignored. The file is streamed as the subscriptions are read, so a failure after the first row cuts it short. CSV and XLSX have the columns id, service_id, service_name, monthly_price.amount, monthly_price.currency, user_id, start_date and end_date, so that a CSV export can be imported again",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "Export subscriptions",
+                "parameters": [
+                    {
+                        "enum": [
+                            "csv",
+                            "ndjson",
+                            "xlsx"
+                        ],
+                        "type": "string",
+                        "description": "Format of the file (default csv)",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Service ID",
+                        "name": "service_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Service name or alias",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency of the monthly price",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimal monthly price in minor units",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximal monthly price in minor units",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Month the subscription is active in (MM-YYYY)",
+                        "name": "active_at",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest start date (MM-YYYY)",
+                        "name": "start_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Latest start date (MM-YYYY)",
+                        "name": "start_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Earliest end date (MM-YYYY)",
+                        "name": "end_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Latest end date (MM-YYYY)",
+                        "name": "end_to",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "id",
+                            "service_name",
+                            "monthly_price",
+                            "start_date",
+                            "end_date"
+                        ],
+                        "type": "string",
+                        "description": "Sort column",
+                        "name": "sort_by",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Sort direction",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Whether deleted subscriptions are exported too",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/import": {
+            "post": {
+                "description": "Create subscriptions from CSV or NDJSON, sent as the request body or as the \"file\" field of a multipart form. NDJSON holds one subscription per line in its JSON shape; CSV has a header naming its columns among id, service_id, service_name, monthly_price.amount, monthly_price.currency, user_id, start_date and end_date, dates formatted as MM-YYYY. Every row is validated like a new subscription. Invalid rows and rows duplicating a stored subscription or an earlier row (same user, service, monthly price and dates) are skipped and listed in the report, the others are saved in batches of 1000",
+                "consumes": [
+                    "text/csv",
+                    "application/x-ndjson",
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "Import subscriptions",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "CSV or NDJSON file",
+                        "name": "file",
+                        "in": "formData"
+                    },
+                    {
+                        "enum": [
+                            "csv",
+                            "ndjson"
+                        ],
+                        "type": "string",
+                        "description": "Format of the file, told by the Content-Type or the file extension by default",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Whether the rows are only checked and nothing is saved",
+                        "name": "dry_run",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/importer.Report"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/{id}": {
+            "get": {
+                "description": "Get a single subscription by its ID",
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "Get subscription by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Whether a deleted subscription is returned too",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of a cached version",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the subscription"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Update an existing subscription by ID. A new monthly_price takes effect from price_effective_from, the current month by default, so earlier months keep their prices. Send the ETag of the version the update is based on in If-Match, or the version in the body, to have the update rejected with 409 when the subscription has changed since. The updated subscription is validated as a whole and rejected with 422 when, for example, the new end_date is before the stored start_date",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "Update subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Subscription data. The id may be omitted and must match the path otherwise",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UpdateSubscription"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version the update is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the updated subscription"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a subscription by its ID. It is kept, excluded from listings and invoices, and can be restored until the purge job removes it",
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "Delete subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Change fields of a subscription with a JSON Merge Patch (RFC 7396, application/merge-patch+json) or a JSON Patch (RFC 6902, application/json-patch+json) applied to models.SubscriptionDocument. Unlike PUT, null and zero values are applied: a null end_date makes the subscription open-ended. The patched subscription is validated before it is saved",
+                "consumes": [
+                    "application/merge-patch+json",
+                    "application/json-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "Patch subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Merge patch object or array of JSON Patch operations",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Month a new monthly price takes effect from (MM-YYYY), the current month by default",
+                        "name": "price_effective_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version the patch is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Subscription"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the patched subscription"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/{id}/history": {
+            "get": {
+                "description": "Get the recorded changes of a subscription, oldest first, with snapshots before and after each change. Purged subscriptions keep their history",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "Get subscription history",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.AuditEntry"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/{id}/prices": {
+            "get": {
+                "description": "Get the monthly prices of a subscription and the months they took effect from",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "Get subscription price history",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.PricePeriod"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/{id}/restore": {
+            "post": {
+                "description": "Restore a deleted subscription that has not been purged yet",
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "Restore subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{user_id}/subscriptions": {
+            "get": {
+                "description": "Get all subscriptions of a user, split into active and historical ones",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Get user subscriptions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.UserSubscriptions"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Succeeds while the process is running, whatever the state of the database",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Liveness check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.HealthStatus"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Succeeds while the service can serve requests: it is not shutting down, and its database is reachable and at the schema version the service expects",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Readiness check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.HealthStatus"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscription": {
+            "put": {
+                "description": "Update an existing subscription by the ID in the body. Superseded by PUT /api/v1/subscriptions/{id}",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Subscription"
+                ],
+                "summary": "Update subscription",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "description": "Subscription data",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UpdateSubscription"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version the update is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the updated subscription"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/version": {
+            "get": {
+                "description": "Get the build of the service and the migration version of its database",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Version",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/routes.VersionInfo"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "apierror.Code": {
+            "type": "string",
+            "enum": [
+                "bad_request",
+                "validation_failed",
+                "not_found",
+                "conflict",
+                "unsupported_media_type",
+                "storage_unavailable",
+                "storage_timeout",
+                "request_canceled",
+                "not_ready",
+                "internal_error"
+            ],
+            "x-enum-varnames": [
+                "CodeBadRequest",
+                "CodeValidation",
+                "CodeNotFound",
+                "CodeConflict",
+                "CodeUnsupportedMediaType",
+                "CodeStorageUnavailable",
+                "CodeStorageTimeout",
+                "CodeCanceled",
+                "CodeNotReady",
+                "CodeInternal"
+            ]
+        },
+        "apierror.Problem": {
+            "description": "Problem details of a failed request (RFC 7807)",
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Stable machine-readable code of the problem\nexample: \"not_found\"",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/apierror.Code"
+                        }
+                    ]
+                },
+                "detail": {
+                    "description": "Explanation specific to this occurrence of the problem\nexample: \"subscription not found\"",
+                    "type": "string"
+                },
+                "errors": {
+                    "description": "Messages of the invalid fields, keyed by their JSON path",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "instance": {
+                    "description": "Path of the request the problem occurred in\nexample: \"/api/v1/subscriptions/1\"",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "HTTP status code\nexample: 404",
+                    "type": "integer"
+                },
+                "title": {
+                    "description": "Short summary of the problem type\nexample: \"Not Found\"",
+                    "type": "string"
+                },
+                "type": {
+                    "description": "URI reference identifying the problem type\nexample: \"about:blank\"",
+                    "type": "string"
+                }
+            }
+        },
+        "importer.Report": {
+            "description": "Outcome of an import, listing the rows that were not imported",
+            "type": "object",
+            "properties": {
+                "dry_run": {
+                    "description": "Whether nothing was saved and Imported counts the rows that would have been\nexample: false",
+                    "type": "boolean"
+                },
+                "duplicates": {
+                    "description": "Number of rows skipped as duplicates\nexample: 1",
+                    "type": "integer"
+                },
+                "failed": {
+                    "description": "Number of invalid rows\nexample: 1",
+                    "type": "integer"
+                },
+                "imported": {
+                    "description": "Number of rows imported\nexample: 1",
+                    "type": "integer"
+                },
+                "rows": {
+                    "description": "Rows that were skipped as duplicates or failed, in the order of the file",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/importer.RowReport"
+                    }
+                },
+                "total": {
+                    "description": "Number of rows read\nexample: 3",
+                    "type": "integer"
+                }
+            }
+        },
+        "importer.RowReport": {
+            "type": "object",
+            "properties": {
+                "duplicate_of": {
+                    "description": "ID of the stored subscription the row duplicates\nexample: 1",
+                    "type": "integer"
+                },
+                "duplicate_of_line": {
+                    "description": "Line of an earlier row of the file the row duplicates\nexample: 1",
+                    "type": "integer"
+                },
+                "error": {
+                    "description": "Problem of a failed row",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    ]
+                },
+                "line": {
+                    "description": "Line of the file the row starts at\nexample: 2",
+                    "type": "integer"
+                },
+                "status": {
+                    "description": "Outcome of the row: duplicate or failed\nexample: \"duplicate\"",
+                    "type": "string"
+                }
+            }
+        },
+        "models.AuditEntry": {
+            "description": "A recorded change of a subscription",
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "Kind of change: create, update, delete, restore or purge\nexample: \"update\"",
+                    "type": "string"
+                },
+                "actor": {
+                    "description": "Who made the change\nexample: \"alice\"",
+                    "type": "string"
+                },
+                "after": {
+                    "description": "Subscription after the change, null when it was purged",
+                    "type": "object"
+                },
+                "before": {
+                    "description": "Subscription before the change, null when it was created",
+                    "type": "object"
+                },
+                "changed_at": {
+                    "description": "Time of the change",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "ID of the entry\nexample: 1",
+                    "type": "integer"
+                },
+                "request_id": {
+                    "description": "ID of the request that made the change\nexample: \"0b9c4f0e-3c5e-4d8f-9a4a-2f1e5d6c7b8a\"",
+                    "type": "string"
+                },
+                "subscription_id": {
+                    "description": "ID of the changed subscription\nexample: 1",
+                    "type": "integer"
                 }
             }
         },
-        "models.SubscriptionInvoiceRequest": {
+        "models.CurrencyRate": {
+            "description": "Monthly exchange rate of a currency pair",
+            "type": "object",
+            "required": [
+                "base",
+                "month",
+                "quote",
+                "rate"
+            ],
+            "properties": {
+                "base": {
+                    "description": "ISO 4217 code of the currency being converted\nexample: \"USD\"",
+                    "type": "string"
+                },
+                "month": {
+                    "description": "Month the rate applies to\nexample: \"06-2025\"",
+                    "type": "string"
+                },
+                "quote": {
+                    "description": "ISO 4217 code of the currency converted into\nexample: \"RUB\"",
+                    "type": "string"
+                },
+                "rate": {
+                    "description": "Units of the quote currency per unit of the base currency, with up to 10 decimal places\nexample: 90.5",
+                    "type": "number"
+                }
+            }
+        },
+        "models.InvoiceLine": {
+            "description": "Amount billed for one subscription in one calendar month",
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "Amount billed for the month",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Money"
+                        }
+                    ]
+                },
+                "month": {
+                    "description": "Billed month\nexample: \"06-2025\"",
+                    "type": "string"
+                },
+                "service_id": {
+                    "description": "ID of the service\nexample: 1",
+                    "type": "integer"
+                },
+                "service_name": {
+                    "description": "Name of the service\nexample: \"Netflix\"",
+                    "type": "string"
+                },
+                "subscription_id": {
+                    "description": "ID of the subscription\nexample: 1",
+                    "type": "integer"
+                },
+                "user_id": {
+                    "description": "ID of the user who owns the subscription\nexample: \"f47ac10b-58cc-4372-a567-0e02b2c3d479\"",
+                    "type": "string"
+                }
+            }
+        },
+        "models.InvoiceMonth": {
+            "description": "Amount billed in one calendar month",
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "Amount billed for the month",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Money"
+                        }
+                    ]
+                },
+                "month": {
+                    "description": "Billed month\nexample: \"06-2025\"",
+                    "type": "string"
+                }
+            }
+        },
+        "models.Money": {
+            "description": "Amount of money in minor units (e.g. kopecks, cents) of an ISO 4217 currency",
+            "type": "object",
+            "required": [
+                "currency"
+            ],
+            "properties": {
+                "amount": {
+                    "description": "Amount in minor units of the currency\nexample: 49900",
+                    "type": "integer"
+                },
+                "currency": {
+                    "description": "ISO 4217 currency code\nexample: \"RUB\"",
+                    "type": "string"
+                }
+            }
+        },
+        "models.PricePeriod": {
+            "description": "A monthly price in effect from a month until the next price period",
+            "type": "object",
+            "properties": {
+                "effective_from": {
+                    "description": "First month charged at the price\nexample: \"06-2025\"",
+                    "type": "string"
+                },
+                "monthly_price": {
+                    "description": "Monthly price",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Money"
+                        }
+                    ]
+                }
+            }
+        },
+        "models.Service": {
+            "description": "A service users can subscribe to",
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "aliases": {
+                    "description": "Alternative names resolving to this service, matched ignoring case and extra whitespace\nexample: [\"netflix premium\"]",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "category": {
+                    "description": "Category of the service\nexample: \"streaming\"",
+                    "type": "string"
+                },
+                "default_price": {
+                    "description": "Default monthly price of the service",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Money"
+                        }
+                    ]
+                },
+                "id": {
+                    "description": "ID of the service\nexample: 1",
+                    "type": "integer"
+                },
+                "name": {
+                    "description": "Canonical name of the service\nexample: \"Netflix\"",
+                    "type": "string"
+                }
+            }
+        },
+        "models.Subscription": {
+            "description": "A subscription that a user has to a service",
             "type": "object",
             "required": [
-                "from_date",
-                "service_name",
-                "to_date",
+                "monthly_price",
+                "start_date",
                 "user_id"
             ],
             "properties": {
+                "end_date": {
+                    "description": "End date of the subscription, not before the start date\nexample: \"06-2006\"",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "ID of the subscription\nexample: 1",
+                    "type": "integer"
+                },
+                "monthly_price": {
+                    "description": "Latest monthly price of the subscription, a positive amount. Earlier months are charged at the\nprices recorded in its price history",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Money"
+                        }
+                    ]
+                },
+                "service_id": {
+                    "description": "ID of the service in the catalogue. Either service_id or service_name is required\nexample: 1",
+                    "type": "integer"
+                },
+                "service_name": {
+                    "description": "Name or alias of the service, resolved through the catalogue to its canonical name\nexample: \"Netflix\"",
+                    "type": "string"
+                },
+                "start_date": {
+                    "description": "Start date of the subscription\nexample: \"06-2006\"",
+                    "type": "string"
+                },
+                "user_id": {
+                    "description": "ID of the user who owns the subscription\nexample: \"f47ac10b-58cc-4372-a567-0e02b2c3d479\"",
+                    "type": "string"
+                }
+            }
+        },
+        "models.SubscriptionInvoice": {
+            "description": "Invoice total with optional grouped totals",
+            "type": "object",
+            "properties": {
+                "groups": {
+                    "description": "Totals per group, present only when group_by is set",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SubscriptionInvoiceGroup"
+                    }
+                },
+                "sum": {
+                    "description": "Total cost of all subscriptions in the period",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Money"
+                        }
+                    ]
+                }
+            }
+        },
+        "models.SubscriptionInvoiceDetails": {
+            "description": "Invoice total with line items per subscription and month",
+            "type": "object",
+            "properties": {
+                "subscriptions": {
+                    "description": "Line items per subscription",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SubscriptionInvoiceItem"
+                    }
+                },
+                "sum": {
+                    "description": "Total cost of all subscriptions in the period",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Money"
+                        }
+                    ]
+                }
+            }
+        },
+        "models.SubscriptionInvoiceGroup": {
+            "description": "Invoice total of one group",
+            "type": "object",
+            "properties": {
+                "month": {
+                    "description": "Month of the group\nexample: \"06-2025\"",
+                    "type": "string"
+                },
+                "service_name": {
+                    "description": "Service name of the group\nexample: \"Netflix\"",
+                    "type": "string"
+                },
+                "sum": {
+                    "description": "Total cost of the group",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Money"
+                        }
+                    ]
+                },
+                "user_id": {
+                    "description": "User of the group\nexample: \"f47ac10b-58cc-4372-a567-0e02b2c3d479\"",
+                    "type": "string"
+                }
+            }
+        },
+        "models.SubscriptionInvoiceItem": {
+            "description": "Invoice line item for a single subscription",
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "Amount billed for the subscription in the period",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Money"
+                        }
+                    ]
+                },
+                "monthly_price": {
+                    "description": "Monthly price in effect in the last billed month",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Money"
+                        }
+                    ]
+                },
+                "months": {
+                    "description": "Amount billed per calendar month",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.InvoiceMonth"
+                    }
+                },
+                "months_billed": {
+                    "description": "Number of months billed in the period\nexample: 3",
+                    "type": "integer"
+                },
+                "service_id": {
+                    "description": "ID of the service\nexample: 1",
+                    "type": "integer"
+                },
+                "service_name": {
+                    "description": "Name of the service\nexample: \"Netflix\"",
+                    "type": "string"
+                },
+                "subscription_id": {
+                    "description": "ID of the subscription\nexample: 1",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.SubscriptionInvoiceRequest": {
+            "type": "object",
+            "required": [
+                "from_date"
+            ],
+            "properties": {
+                "currency": {
+                    "description": "ISO 4217 currency to report the totals in. Optional, required only when\nthe subscriptions in the period are priced in different currencies\nexample: \"RUB\"",
+                    "type": "string"
+                },
                 "from_date": {
                     "description": "Start date of the period (month/year)\nexample: \"06-2006\"",
                     "type": "string"
                 },
+                "group_by": {
+                    "description": "Dimension to group the totals by. Optional\nexample: \"service\"",
+                    "type": "string",
+                    "enum": [
+                        "service",
+                        "user",
+                        "month"
+                    ]
+                },
+                "include_deleted": {
+                    "description": "Whether deleted subscriptions are billed too\nexample: false",
+                    "type": "boolean"
+                },
+                "service_id": {
+                    "description": "Service ID. Optional, all services are included when empty\nexample: 1",
+                    "type": "integer"
+                },
                 "service_name": {
-                    "description": "Service name\nexample: \"Netflix\"",
+                    "description": "Service name or alias, resolved through the catalogue to service_id. Optional\nexample: \"Netflix\"",
                     "type": "string"
                 },
                 "to_date": {
-                    "description": "End date of the period (month/year). Can be null, in which case the current date is used\nexample: \"06-2006\"",
+                    "description": "End date of the period (month/year), not before from_date. Can be null, in which case the period runs through the current month. The period may span at most 120 months\nexample: \"06-2006\"",
                     "type": "string"
                 },
                 "user_id": {
-                    "description": "Unique user identifier\nexample: \"f47ac10b-58cc-4372-a567-0e02b2c3d479\"",
+                    "description": "Unique user identifier. Optional, all users are included when empty\nexample: \"f47ac10b-58cc-4372-a567-0e02b2c3d479\"",
+                    "type": "string"
+                }
+            }
+        },
+        "models.SubscriptionPage": {
+            "description": "A page of subscriptions with pagination metadata",
+            "type": "object",
+            "properties": {
+                "items": {
+                    "description": "Subscriptions on this page",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Subscription"
+                    }
+                },
+                "next_cursor": {
+                    "description": "Cursor to pass to get the next page, null on the last page\nexample: eyJzIjoiaWQiLCJpIjoyMH0",
                     "type": "string"
+                },
+                "total_count": {
+                    "description": "Number of subscriptions matching the filters\nexample: 42",
+                    "type": "integer"
                 }
             }
         },
@@ -403,11 +2461,23 @@ const docTemplate = `{
                     "type": "integer"
                 },
                 "monthly_price": {
-                    "description": "Monthly price of the subscription\nexample: 100",
+                    "description": "New monthly price of the subscription",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Money"
+                        }
+                    ]
+                },
+                "price_effective_from": {
+                    "description": "Month the new monthly price takes effect from. Defaults to the current month;\nearlier months keep being charged at the prices in effect then\nexample: \"06-2006\"",
+                    "type": "string"
+                },
+                "service_id": {
+                    "description": "ID of the service in the catalogue\nexample: 1",
                     "type": "integer"
                 },
                 "service_name": {
-                    "description": "Name of the service\nexample: \"Netflix\"",
+                    "description": "Name or alias of the service, resolved through the catalogue\nexample: \"Netflix\"",
                     "type": "string"
                 },
                 "start_date": {
@@ -417,6 +2487,130 @@ const docTemplate = `{
                 "user_id": {
                     "description": "ID of the user\nexample: \"f47ac10b-58cc-4372-a567-0e02b2c3d479\"",
                     "type": "string"
+                },
+                "version": {
+                    "description": "Version the update is based on. Optional; when set, the update is rejected\nif the subscription has changed since\nexample: 1",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.UserSubscriptions": {
+            "description": "Subscriptions of a user split into active and historical ones",
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "Subscriptions that have not ended before the current month",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Subscription"
+                    }
+                },
+                "historical": {
+                    "description": "Subscriptions that ended before the current month",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Subscription"
+                    }
+                },
+                "user_id": {
+                    "description": "ID of the user\nexample: \"f47ac10b-58cc-4372-a567-0e02b2c3d479\"",
+                    "type": "string"
+                }
+            }
+        },
+        "routes.BulkItem": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Problem of a failed item",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    ]
+                },
+                "id": {
+                    "description": "ID of the subscription\nexample: 1",
+                    "type": "integer"
+                },
+                "index": {
+                    "description": "Position of the item in the request, starting at 0\nexample: 0",
+                    "type": "integer"
+                },
+                "status": {
+                    "description": "HTTP status the item would have been answered with on its own\nexample: 201",
+                    "type": "integer"
+                },
+                "version": {
+                    "description": "Version of the subscription after the change\nexample: 2",
+                    "type": "integer"
+                }
+            }
+        },
+        "routes.BulkResult": {
+            "description": "Outcome of a bulk request",
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "description": "Number of items that failed\nexample: 1",
+                    "type": "integer"
+                },
+                "items": {
+                    "description": "Outcome of each item, in the order of the request",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/routes.BulkItem"
+                    }
+                },
+                "succeeded": {
+                    "description": "Number of items carried out\nexample: 2",
+                    "type": "integer"
+                }
+            }
+        },
+        "routes.HealthStatus": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "description": "example: ok",
+                    "type": "string"
+                }
+            }
+        },
+        "routes.VersionInfo": {
+            "type": "object",
+            "properties": {
+                "expected_schema_version": {
+                    "description": "Migration version the service expects the database at\nexample: 1693551600",
+                    "type": "integer"
+                },
+                "go_version": {
+                    "description": "example: go1.24.0",
+                    "type": "string"
+                },
+                "modified": {
+                    "description": "Whether the service was built with uncommitted changes",
+                    "type": "boolean"
+                },
+                "revision": {
+                    "description": "Commit the service was built from, when known\nexample: 9f2c1e4b7a0d",
+                    "type": "string"
+                },
+                "revision_time": {
+                    "description": "Time of that commit, when known\nexample: 2026-10-01T12:00:00Z",
+                    "type": "string"
+                },
+                "schema_dirty": {
+                    "description": "Whether the last migration failed halfway",
+                    "type": "boolean"
+                },
+                "schema_version": {
+                    "description": "Migration version the database is at, null when it cannot be read\nexample: 1693551600",
+                    "type": "integer"
+                },
+                "version": {
+                    "description": "Release of the service\nexample: 1.4.0",
+                    "type": "string"
                 }
             }
         }
@@ -430,7 +2624,7 @@ var SwaggerInfo = &swag.Spec{
 	BasePath:         "/",
 	Schemes:          []string{},
 	Title:            "Users Online Subscriptions Data Aggregator API",
-	Description:      "API documentation for Users Online Subscriptions Data Aggregator",
+	Description:      "API documentation for Users Online Subscriptions Data Aggregator. Routes outside /api/v1 are deprecated aliases and answer with Deprecation, Sunset and Link headers",
 	InfoInstanceName: "swagger",
 	SwaggerTemplate:  docTemplate,
 	LeftDelim:        "{{",
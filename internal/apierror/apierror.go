@@ -0,0 +1,241 @@
+// Package apierror reports failed requests as RFC 7807 problem details
+// (application/problem+json) carrying a stable, machine-readable code.
+package apierror
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/logger"
+)
+
+// ContentType is the media type of problem responses.
+const ContentType = "application/problem+json"
+
+// StatusClientClosedRequest is the status of a request the client canceled
+// before it was answered, after the nginx convention. The client does not get
+// to see it; it is only there for access logs.
+const StatusClientClosedRequest = 499
+
+// Code identifies the kind of a problem. Codes are part of the API and do not
+// change between releases.
+type Code string
+
+const (
+	// CodeBadRequest is a request that cannot be read: a malformed body, path
+	// or header.
+	CodeBadRequest Code = "bad_request"
+	// CodeValidation is a request whose fields are invalid; the fields and
+	// their messages are listed in Problem.Errors.
+	CodeValidation Code = "validation_failed"
+	// CodeNotFound is a resource that does not exist.
+	CodeNotFound Code = "not_found"
+	// CodeConflict is a change that conflicts with the current state of a
+	// resource, such as a stale version or a duplicate name.
+	CodeConflict Code = "conflict"
+	// CodeUnsupportedMediaType is a body of a content type the route does not accept.
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	// CodeStorageUnavailable is a request that failed because the database
+	// could not be reached. It may be retried.
+	CodeStorageUnavailable Code = "storage_unavailable"
+	// CodeStorageTimeout is a request that failed because the database did not
+	// answer in time. It may be retried.
+	CodeStorageTimeout Code = "storage_timeout"
+	// CodeCanceled is a request the client canceled before it was answered.
+	CodeCanceled Code = "request_canceled"
+	// CodeNotReady is a readiness check that failed: the service is shutting
+	// down, or its database is unreachable or not at the expected schema version.
+	CodeNotReady Code = "not_ready"
+	// CodeInternal is any other failure of the service.
+	CodeInternal Code = "internal_error"
+)
+
+// Problem is the body of an error response.
+// @Description Problem details of a failed request (RFC 7807)
+type Problem struct {
+	// URI reference identifying the problem type
+	// example: "about:blank"
+	Type string `json:"type"`
+	// Short summary of the problem type
+	// example: "Not Found"
+	Title string `json:"title"`
+	// HTTP status code
+	// example: 404
+	Status int `json:"status"`
+	// Explanation specific to this occurrence of the problem
+	// example: "subscription not found"
+	Detail string `json:"detail,omitempty"`
+	// Path of the request the problem occurred in
+	// example: "/api/v1/subscriptions/1"
+	Instance string `json:"instance,omitempty"`
+	// Stable machine-readable code of the problem
+	// example: "not_found"
+	Code Code `json:"code"`
+	// Messages of the invalid fields, keyed by their JSON path
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// Error is an error reported to the client as a problem.
+type Error struct {
+	Code   Code
+	Status int
+	Detail string
+	Fields map[string]string
+	// Err is the underlying error. It is logged, not sent to the client.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Detail + ": " + e.Err.Error()
+	}
+	return e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithStatus returns a copy of e answered with status instead.
+func (e *Error) WithStatus(status int) *Error {
+	c := *e
+	c.Status = status
+	return &c
+}
+
+func BadRequest(detail string) *Error {
+	return &Error{Code: CodeBadRequest, Status: http.StatusBadRequest, Detail: detail}
+}
+
+// Invalid reports the fields of a request that failed validation, mapped to
+// their messages.
+func Invalid(fields map[string]string) *Error {
+	return &Error{Code: CodeValidation, Status: http.StatusBadRequest, Detail: "request has invalid fields", Fields: fields}
+}
+
+// Unprocessable reports a well-formed request that cannot be carried out as
+// asked, such as one naming an unknown service.
+func Unprocessable(err error) *Error {
+	return &Error{Code: CodeValidation, Status: http.StatusUnprocessableEntity, Detail: err.Error(), Err: err}
+}
+
+func NotFound(detail string) *Error {
+	return &Error{Code: CodeNotFound, Status: http.StatusNotFound, Detail: detail}
+}
+
+func Conflict(err error) *Error {
+	return &Error{Code: CodeConflict, Status: http.StatusConflict, Detail: err.Error(), Err: err}
+}
+
+func UnsupportedMediaType(detail string) *Error {
+	return &Error{Code: CodeUnsupportedMediaType, Status: http.StatusUnsupportedMediaType, Detail: detail}
+}
+
+// NotReady reports that the service cannot serve requests for the reason in detail.
+func NotReady(err error, detail string) *Error {
+	return &Error{Code: CodeNotReady, Status: http.StatusServiceUnavailable, Detail: detail, Err: err}
+}
+
+// Canceled reports a request the client canceled, which err is the result of.
+func Canceled(err error) *Error {
+	return &Error{Code: CodeCanceled, Status: StatusClientClosedRequest, Detail: "request was canceled by the client", Err: err}
+}
+
+// Internal reports a failure of the service described by detail. A failure
+// to reach the database is reported as storage_unavailable and a query that
+// ran out of time as storage_timeout instead, so that clients know to retry.
+func Internal(err error, detail string) *Error {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return Canceled(err)
+	case timedOut(err):
+		return &Error{Code: CodeStorageTimeout, Status: http.StatusGatewayTimeout, Detail: "storage did not answer in time, try again later", Err: err}
+	case unavailable(err):
+		return &Error{Code: CodeStorageUnavailable, Status: http.StatusServiceUnavailable, Detail: "storage is unavailable, try again later", Err: err}
+	}
+	return &Error{Code: CodeInternal, Status: http.StatusInternalServerError, Detail: detail, Err: err}
+}
+
+// Respond writes err as a problem and aborts the request. Errors other than
+// *Error are reported as internal errors.
+func Respond(ctx *gin.Context, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = Internal(err, http.StatusText(http.StatusInternalServerError))
+	}
+	// once the client has gone away, the queries of its request fail with
+	// whatever error the driver makes of the cancellation
+	if e.Status >= http.StatusInternalServerError && errors.Is(ctx.Request.Context().Err(), context.Canceled) {
+		e = Canceled(err)
+	}
+	// a failed readiness check is a state of the service rather than a failure
+	if e.Status >= http.StatusInternalServerError && e.Code != CodeNotReady {
+		logger.Log.Error("request failed", slog.String("code", string(e.Code)), slog.String("path", ctx.Request.URL.Path), slog.Any("err", e.Err))
+	}
+	if e.Code == CodeCanceled {
+		logger.Log.Debug("request canceled", slog.String("path", ctx.Request.URL.Path))
+	}
+	if e.Code == CodeStorageUnavailable || e.Code == CodeStorageTimeout {
+		ctx.Header("Retry-After", "5")
+	}
+	ctx.Header("Content-Type", ContentType)
+	ctx.AbortWithStatusJSON(e.Status, e.Problem(ctx.Request.URL.Path))
+}
+
+// Problem returns the problem details of e that occurred in instance.
+func (e *Error) Problem(instance string) Problem {
+	return Problem{
+		Type:     "about:blank",
+		Title:    statusText(e.Status),
+		Status:   e.Status,
+		Detail:   e.Detail,
+		Instance: instance,
+		Code:     e.Code,
+		Errors:   e.Fields,
+	}
+}
+
+func statusText(status int) string {
+	if status == StatusClientClosedRequest {
+		return "Client Closed Request"
+	}
+	return http.StatusText(status)
+}
+
+// timedOut reports whether err means a query ran out of time: its context
+// expired, or the server canceled it (query_canceled, 57014), which lib/pq
+// returns when it cancels a query on an expired context.
+func timedOut(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var state interface{ SQLState() string }
+	return errors.As(err, &state) && state.SQLState() == "57014"
+}
+
+// unavailable reports whether err means the database could not be reached,
+// as opposed to the query failing.
+func unavailable(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	// connection exceptions (08), insufficient resources such as too many
+	// connections (53) and server shutdowns (57P)
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) {
+		code := state.SQLState()
+		return strings.HasPrefix(code, "08") || strings.HasPrefix(code, "53") || strings.HasPrefix(code, "57P")
+	}
+	return false
+}
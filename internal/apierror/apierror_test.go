@@ -0,0 +1,55 @@
+package apierror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/logger"
+)
+
+func TestInternal(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		code   Code
+		status int
+	}{
+		{"client canceled", fmt.Errorf("list: %w", context.Canceled), CodeCanceled, StatusClientClosedRequest},
+		{"deadline exceeded", fmt.Errorf("list: %w", context.DeadlineExceeded), CodeStorageTimeout, http.StatusGatewayTimeout},
+		{"query canceled", &pq.Error{Code: "57014"}, CodeStorageTimeout, http.StatusGatewayTimeout},
+		{"connection failure", &pq.Error{Code: "08006"}, CodeStorageUnavailable, http.StatusServiceUnavailable},
+		{"too many connections", &pq.Error{Code: "53300"}, CodeStorageUnavailable, http.StatusServiceUnavailable},
+		{"other failure", errors.New("boom"), CodeInternal, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := Internal(tt.err, "failed")
+			if e.Code != tt.code || e.Status != tt.status {
+				t.Errorf("Internal() = %s %d, want %s %d", e.Code, e.Status, tt.code, tt.status)
+			}
+		})
+	}
+}
+
+func TestRespondCanceledRequest(t *testing.T) {
+	logger.InitLogger("local")
+	gin.SetMode(gin.TestMode)
+	reqCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/api/v1/subscriptions", nil).WithContext(reqCtx)
+
+	// the driver reports the cancellation as a canceled statement
+	Respond(ctx, Internal(&pq.Error{Code: "57014"}, "failed"))
+
+	if w.Code != StatusClientClosedRequest {
+		t.Fatalf("status = %d, want %d", w.Code, StatusClientClosedRequest)
+	}
+}
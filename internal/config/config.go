@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/joho/godotenv"
@@ -13,6 +14,7 @@ type Config struct {
 	Env          string `yaml:"env" env:"ENV" env-required:"true"`
 	ServerConfig `yaml:"server"`
 	DBConfig     `yaml:"db"`
+	PurgeConfig  `yaml:"purge"`
 }
 
 type ServerConfig struct {
@@ -25,6 +27,40 @@ type DBConfig struct {
 	User     string `yaml:"user" env:"DB_USER"`
 	Password string `yaml:"password" env:"DB_PASSWORD"`
 	Name     string `yaml:"name" env:"DB_NAME"`
+	// ConnectTimeout bounds how long startup waits for the database to accept
+	// connections, retrying with a growing delay until it does.
+	ConnectTimeout time.Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT" env-default:"30s"`
+	// AutoMigrate applies the pending migrations from MigrationsPath at
+	// startup. Without it they are applied with the migrate command.
+	AutoMigrate    bool   `yaml:"auto_migrate" env:"DB_AUTO_MIGRATE" env-default:"true"`
+	MigrationsPath string `yaml:"migrations_path" env:"DB_MIGRATIONS_PATH" env-default:"migrations"`
+	// SSLMode, SSLRootCert, SSLCert and SSLKey are passed to the driver as the
+	// sslmode, sslrootcert, sslcert and sslkey connection parameters.
+	SSLMode     string `yaml:"ssl_mode" env:"DB_SSL_MODE" env-default:"disable"`
+	SSLRootCert string `yaml:"ssl_root_cert" env:"DB_SSL_ROOT_CERT"`
+	SSLCert     string `yaml:"ssl_cert" env:"DB_SSL_CERT"`
+	SSLKey      string `yaml:"ssl_key" env:"DB_SSL_KEY"`
+	// ApplicationName names the connections in pg_stat_activity.
+	ApplicationName string `yaml:"application_name" env:"DB_APPLICATION_NAME" env-default:"subscriptions-app"`
+	// StatementTimeout makes the server cancel statements running longer. Zero
+	// keeps the server default.
+	StatementTimeout time.Duration `yaml:"statement_timeout" env:"DB_STATEMENT_TIMEOUT"`
+	// QueryTimeout bounds each query, on top of the deadline of the request
+	// running it. Zero leaves queries bounded by the request alone.
+	QueryTimeout time.Duration `yaml:"query_timeout" env:"DB_QUERY_TIMEOUT" env-default:"5s"`
+	// The connection pool, see sql.DB. Zero means no limit for all of them but
+	// MaxIdleConns, where it keeps no idle connections.
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" env-default:"25"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" env-default:"10"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" env-default:"30m"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" env-default:"5m"`
+}
+
+// PurgeConfig controls the job removing deleted subscriptions for good once
+// they have been deleted for longer than Retention. A zero Interval disables it.
+type PurgeConfig struct {
+	Retention time.Duration `yaml:"retention" env:"PURGE_RETENTION" env-default:"720h"`
+	Interval  time.Duration `yaml:"interval" env:"PURGE_INTERVAL" env-default:"1h"`
 }
 
 func MustLoad() *Config {
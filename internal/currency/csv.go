@@ -0,0 +1,71 @@
+package currency
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+)
+
+// validate checks currency codes like the binding of PUT /admin/rates does.
+var validate = validator.New()
+
+var ratesCSVHeader = []string{"base", "quote", "month", "rate"}
+
+// ParseRatesCSV reads rates from CSV with the header "base,quote,month,rate",
+// where month is formatted as MM-YYYY. The first invalid line aborts parsing.
+func ParseRatesCSV(r io.Reader) ([]models.CurrencyRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(ratesCSVHeader)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("csv is empty")
+		}
+		return nil, err
+	}
+	for i, column := range ratesCSVHeader {
+		if strings.ToLower(strings.TrimSpace(header[i])) != column {
+			return nil, fmt.Errorf("csv header must be %s", strings.Join(ratesCSVHeader, ","))
+		}
+	}
+
+	var rates []models.CurrencyRate
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rates, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		rate, err := parseRateRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rates = append(rates, rate)
+	}
+}
+
+func parseRateRecord(record []string) (models.CurrencyRate, error) {
+	base, quote := strings.ToUpper(record[0]), strings.ToUpper(record[1])
+	if validate.Var(base, "iso4217") != nil || validate.Var(quote, "iso4217") != nil || base == quote {
+		return models.CurrencyRate{}, fmt.Errorf("invalid currency pair %s/%s", record[0], record[1])
+	}
+	month, err := time.Parse("01-2006", record[2])
+	if err != nil {
+		return models.CurrencyRate{}, fmt.Errorf("invalid month %q, expected MM-YYYY", record[2])
+	}
+	rate, err := models.ParseRate(record[3])
+	if err != nil || rate.Rat().Sign() <= 0 {
+		return models.CurrencyRate{}, fmt.Errorf("invalid rate %q", record[3])
+	}
+	return models.CurrencyRate{Base: base, Quote: quote, Month: models.FromTime(month), Rate: rate}, nil
+}
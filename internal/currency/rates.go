@@ -0,0 +1,121 @@
+package currency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/repository"
+)
+
+// RatesConverter converts money at the monthly rates stored in a RateRepository.
+// The latest rate for the month or an earlier one is used, falling back to the
+// inverse of the opposite pair. Rates are per major unit, so amounts are scaled
+// by the minor unit exponents of both currencies. The arithmetic is exact and
+// only the converted amount is rounded, to the nearest minor unit with halves
+// away from zero.
+type RatesConverter struct {
+	rates repository.RateRepository
+	// cache holds the rates looked up so far, nil when they are not kept
+	cache map[rateKey]cachedRate
+}
+
+type rateKey struct {
+	base, quote string
+	month       models.MonthYear
+}
+
+type cachedRate struct {
+	rate *big.Rat
+	err  error
+}
+
+// errAmountOutOfRange is a converted amount that does not fit in int64 minor units.
+var errAmountOutOfRange = errors.New("converted amount is out of range")
+
+func NewRatesConverter(rates repository.RateRepository) *RatesConverter {
+	return &RatesConverter{rates: rates}
+}
+
+// WithCache returns a converter that looks each rate up once, to be used for a
+// single request. It is not safe for concurrent use.
+func (c *RatesConverter) WithCache() *RatesConverter {
+	return &RatesConverter{rates: c.rates, cache: make(map[rateKey]cachedRate)}
+}
+
+func (c *RatesConverter) Convert(ctx context.Context, m models.Money, currency string, month models.MonthYear) (models.Money, error) {
+	rate, err := c.cachedRate(ctx, m.Currency, currency, month)
+	if err != nil {
+		return models.Money{}, err
+	}
+	amount := new(big.Rat).SetInt64(m.Amount)
+	amount.Mul(amount, rate)
+	exp := models.MinorUnitExponent(currency) - models.MinorUnitExponent(m.Currency)
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(exp))), nil))
+	if exp >= 0 {
+		amount.Mul(amount, scale)
+	} else {
+		amount.Quo(amount, scale)
+	}
+	converted, ok := round(amount)
+	if !ok {
+		return models.Money{}, fmt.Errorf("%w: %s %d in %s", errAmountOutOfRange, m.Currency, m.Amount, currency)
+	}
+	return models.Money{Amount: converted, Currency: currency}, nil
+}
+
+// round returns r rounded to the nearest integer, halves away from zero, and
+// whether it fits in int64.
+func round(r *big.Rat) (int64, bool) {
+	q, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if rem.Abs(rem).Lsh(rem, 1).Cmp(r.Denom()) >= 0 {
+		q.Add(q, big.NewInt(int64(r.Sign())))
+	}
+	return q.Int64(), q.IsInt64()
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// cachedRate is rate, remembered when c keeps a cache. Missing rates are
+// remembered too; failed lookups are not.
+func (c *RatesConverter) cachedRate(ctx context.Context, base, quote string, month models.MonthYear) (*big.Rat, error) {
+	if c.cache == nil {
+		return c.rate(ctx, base, quote, month)
+	}
+	key := rateKey{base, quote, month}
+	if r, ok := c.cache[key]; ok {
+		return r.rate, r.err
+	}
+	rate, err := c.rate(ctx, base, quote, month)
+	if err == nil || errors.Is(err, models.ErrConversionUnavailable) {
+		c.cache[key] = cachedRate{rate, err}
+	}
+	return rate, err
+}
+
+func (c *RatesConverter) rate(ctx context.Context, base, quote string, month models.MonthYear) (*big.Rat, error) {
+	rate, err := c.rates.FindRate(ctx, base, quote, month)
+	if err == nil {
+		return rate.Rate.Rat(), nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	rate, err = c.rates.FindRate(ctx, quote, base, month)
+	if err == nil && rate.Rate.Rat().Sign() != 0 {
+		return new(big.Rat).Inv(rate.Rate.Rat()), nil
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w: no %s/%s rate for %s",
+		models.ErrConversionUnavailable, base, quote, month.ToTime().Format("01-2006"))
+}
@@ -0,0 +1,84 @@
+package currency
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/repository"
+)
+
+func TestConvert(t *testing.T) {
+	ctx := context.Background()
+	month := models.FromTime(time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC))
+	var rates []models.CurrencyRate
+	for _, r := range []struct{ base, quote, rate string }{
+		{"EUR", "USD", "1.0834567891"},
+		{"USD", "JPY", "150"},
+		{"KWD", "USD", "3.25"},
+		{"USD", "RUB", "90"},
+	} {
+		rate, err := models.ParseRate(r.rate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rates = append(rates, models.CurrencyRate{Base: r.base, Quote: r.quote, Month: month, Rate: rate})
+	}
+	repo := repository.NewMemoryRateRepository()
+	if err := repo.SaveRates(ctx, rates); err != nil {
+		t.Fatal(err)
+	}
+	converter := NewRatesConverter(repo)
+
+	tests := []struct {
+		name string
+		from models.Money
+		to   string
+		want int64
+	}{
+		// a float product comes out as 9758911183325588
+		{"high-precision rate on an amount above 2^53", models.Money{Amount: 1<<53 + 1, Currency: "EUR"}, "USD", 9758911183325589},
+		{"into a currency without minor units", models.Money{Amount: 12345, Currency: "USD"}, "JPY", 18518},
+		{"halves round away from zero", models.Money{Amount: -12345, Currency: "USD"}, "JPY", -18518},
+		{"from a currency with three decimals", models.Money{Amount: 1000, Currency: "KWD"}, "USD", 325},
+		{"at the inverse of the opposite pair", models.Money{Amount: 9000, Currency: "RUB"}, "USD", 100},
+		{"a third at the inverse rate", models.Money{Amount: 3000, Currency: "RUB"}, "USD", 33},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := converter.Convert(ctx, tt.from, tt.to, month)
+			if err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if got.Amount != tt.want || got.Currency != tt.to {
+				t.Errorf("Convert() = %d %s, want %d %s", got.Amount, got.Currency, tt.want, tt.to)
+			}
+		})
+	}
+
+	if _, err := converter.Convert(ctx, models.Money{Amount: math.MaxInt64, Currency: "USD"}, "JPY", month); !errors.Is(err, errAmountOutOfRange) {
+		t.Errorf("Convert() error = %v, want %v", err, errAmountOutOfRange)
+	}
+	if _, err := converter.Convert(ctx, models.Money{Amount: 100, Currency: "USD"}, "GBP", month); !errors.Is(err, models.ErrConversionUnavailable) {
+		t.Errorf("Convert() error = %v, want %v", err, models.ErrConversionUnavailable)
+	}
+}
+
+func TestParseRatesCSV(t *testing.T) {
+	rates, err := ParseRatesCSV(strings.NewReader("base,quote,month,rate\nusd,rub,06-2025,92.1234567891\n"))
+	if err != nil {
+		t.Fatalf("ParseRatesCSV() error = %v", err)
+	}
+	if len(rates) != 1 || rates[0].Base != "USD" || rates[0].Rate.String() != "92.1234567891" {
+		t.Errorf("ParseRatesCSV() = %+v", rates)
+	}
+	for _, rate := range []string{"0", "-1", "1/3", "abc"} {
+		if _, err := ParseRatesCSV(strings.NewReader("base,quote,month,rate\nUSD,RUB,06-2025," + rate + "\n")); err == nil {
+			t.Errorf("ParseRatesCSV() accepted rate %q", rate)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Package export writes tables of rows as CSV, NDJSON or XLSX, one row at a
+// time, so that exports of any size stream straight to the client.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Format is a file format rows are exported in.
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+	FormatXLSX   Format = "xlsx"
+)
+
+// ParseFormat returns the format named s, CSV when s is empty.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case "":
+		return FormatCSV, nil
+	case FormatCSV, FormatNDJSON, FormatXLSX:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected %s, %s or %s", s, FormatCSV, FormatNDJSON, FormatXLSX)
+}
+
+// ContentType is the media type of files in format f.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv"
+}
+
+// Writer writes the rows of an export. Close must be called after the last row
+// to complete the file.
+type Writer interface {
+	// Write writes one row: NDJSON writes v as JSON, the tabular formats write
+	// cells, which hold strings, int64s or nil for empty cells.
+	Write(v any, cells []any) error
+	Close() error
+}
+
+// NewWriter returns a writer of rows in format f to w. Tabular formats start
+// with a header of columns.
+func NewWriter(w io.Writer, f Format, columns []string) (Writer, error) {
+	var writer Writer
+	var err error
+	switch f {
+	case FormatCSV:
+		writer, err = newCSVWriter(w, columns)
+	case FormatNDJSON:
+		writer = newNDJSONWriter(w)
+	case FormatXLSX:
+		writer, err = newXLSXWriter(w, columns)
+	default:
+		return nil, fmt.Errorf("unknown format %q", f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+	record []string
+}
+
+func newCSVWriter(w io.Writer, columns []string) (*csvWriter, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(columns); err != nil {
+		return nil, err
+	}
+	return &csvWriter{writer: writer, record: make([]string, len(columns))}, nil
+}
+
+func (w *csvWriter) Write(_ any, cells []any) error {
+	for i, cell := range cells {
+		w.record[i] = formatCell(cell)
+	}
+	return w.writer.Write(w.record)
+}
+
+func (w *csvWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type ndjsonWriter struct {
+	buf     *bufio.Writer
+	encoder *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	buf := bufio.NewWriter(w)
+	return &ndjsonWriter{buf: buf, encoder: json.NewEncoder(buf)}
+}
+
+// Write writes v on a line of its own, as json.Encoder ends every value with a
+// newline.
+func (w *ndjsonWriter) Write(v any, _ []any) error {
+	return w.encoder.Encode(v)
+}
+
+func (w *ndjsonWriter) Close() error {
+	return w.buf.Flush()
+}
+
+// formatCell formats a cell of a tabular format as text.
+func formatCell(cell any) string {
+	switch c := cell.(type) {
+	case nil:
+		return ""
+	case string:
+		return c
+	case int64:
+		return strconv.FormatInt(c, 10)
+	}
+	return fmt.Sprint(cell)
+}
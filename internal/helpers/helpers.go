@@ -1,46 +1,144 @@
 package helpers
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
 	"reflect"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/apierror"
 )
 
 func BindJSONWithValidation(ctx *gin.Context, obj any) bool {
-	if err := ctx.ShouldBindJSON(obj); err != nil {
-		var verr validator.ValidationErrors
-		if errors.As(err, &verr) {
-			out := make(map[string]string)
-			val := reflect.ValueOf(obj).Elem()
-			typ := val.Type()
-			for _, fe := range verr {
-				field := fe.StructField()
-				jsonName := field
-
-				if f, ok := typ.FieldByName(field); ok {
-					tag := f.Tag.Get("json")
-					if tag != "" {
-						jsonName = strings.Split(tag, ",")[0] // берём до запятой
-					}
-				}
+	return bindWithValidation(ctx, obj, ctx.ShouldBindJSON(obj), "json")
+}
+
+// BindJSONForPath binds the body of a request to a resource addressed by its
+// path. fromPath fills in what the path determines, such as the ID, before the
+// body is validated; an error from it means the body contradicts the path.
+func BindJSONForPath(ctx *gin.Context, obj any, fromPath func() error) bool {
+	if ctx.Request.Body == nil {
+		apierror.Respond(ctx, apierror.BadRequest("request body is missing"))
+		return false
+	}
+	if err := json.NewDecoder(ctx.Request.Body).Decode(obj); err != nil {
+		apierror.Respond(ctx, apierror.BadRequest(err.Error()))
+		return false
+	}
+	if err := fromPath(); err != nil {
+		apierror.Respond(ctx, apierror.BadRequest(err.Error()))
+		return false
+	}
+	return bindWithValidation(ctx, obj, binding.Validator.ValidateStruct(obj), "json")
+}
 
-				switch fe.Tag() {
-				case "required":
-					out[jsonName] = "field is required"
-				default:
-					out[jsonName] = fmt.Sprintf("failed validation: %s", fe.Tag())
+func BindQueryWithValidation(ctx *gin.Context, obj any) bool {
+	return bindWithValidation(ctx, obj, ctx.ShouldBindQuery(obj), "form")
+}
+
+func bindWithValidation(ctx *gin.Context, obj any, err error, tagName string) bool {
+	if err != nil {
+		apierror.Respond(ctx, invalid(obj, err, tagName))
+		return false
+	}
+	return true
+}
+
+// Validate validates obj like BindJSONWithValidation, returning the problem
+// instead of responding with it. It returns nil when obj is valid.
+func Validate(obj any) *apierror.Error {
+	if err := binding.Validator.ValidateStruct(obj); err != nil {
+		return invalid(obj, err, "json")
+	}
+	return nil
+}
+
+func invalid(obj any, err error, tagName string) *apierror.Error {
+	if out, ok := validationDetails(obj, err, tagName); ok {
+		return apierror.Invalid(out)
+	}
+	return apierror.BadRequest(err.Error())
+}
+
+// RespondInvalid responds with status and the fields of obj that failed
+// validation, or the error message when err is not a validation error.
+func RespondInvalid(ctx *gin.Context, obj any, err error, status int) {
+	apierror.Respond(ctx, ValidationError(obj, err, status))
+}
+
+// ValidationError is the problem RespondInvalid responds with.
+func ValidationError(obj any, err error, status int) *apierror.Error {
+	if out, ok := validationDetails(obj, err, "json"); ok {
+		return apierror.Invalid(out).WithStatus(status)
+	}
+	return apierror.Unprocessable(err).WithStatus(status)
+}
+
+// validationDetails maps the fields of obj that failed validation to their
+// messages. It reports false when err is not a validation error.
+func validationDetails(obj any, err error, tagName string) (map[string]string, bool) {
+	var verr validator.ValidationErrors
+	if !errors.As(err, &verr) {
+		return nil, false
+	}
+	out := make(map[string]string)
+	typ := reflect.ValueOf(obj).Elem().Type()
+	for _, fe := range verr {
+		jsonName := fieldPath(typ, fe.StructNamespace(), tagName)
+
+		switch fe.Tag() {
+		case "required":
+			out[jsonName] = "field is required"
+		case "required_without":
+			ns := fe.StructNamespace()
+			other := fieldPath(typ, ns[:strings.LastIndex(ns, ".")+1]+fe.Param(), tagName)
+			out[jsonName] = fmt.Sprintf("field is required when %s is not set", other)
+		case "uuid":
+			out[jsonName] = "must be a valid UUID other than the nil UUID"
+		case "monthyear":
+			out[jsonName] = "must be a valid month formatted as MM-YYYY"
+		case "notblank":
+			out[jsonName] = "must not be blank"
+		case "gt":
+			out[jsonName] = fmt.Sprintf("must be greater than %s", fe.Param())
+		case "gtefield":
+			out[jsonName] = fmt.Sprintf("must not be before %s", fe.Param())
+		case "maxmonths":
+			out[jsonName] = fmt.Sprintf("period must not span more than %s months", fe.Param())
+		case "oneof":
+			out[jsonName] = fmt.Sprintf("must be one of: %s", fe.Param())
+		case "iso4217":
+			out[jsonName] = "must be an ISO 4217 currency code"
+		default:
+			out[jsonName] = fmt.Sprintf("failed validation: %s", fe.Tag())
+		}
+	}
+	return out, true
+}
+
+// fieldPath converts a validator namespace such as "Subscription.MonthlyPrice.Currency"
+// into the dotted path of tag names, e.g. "monthly_price.currency".
+func fieldPath(typ reflect.Type, namespace string, tagName string) string {
+	parts := strings.Split(namespace, ".")[1:]
+	names := make([]string, 0, len(parts))
+	for _, part := range parts {
+		name := part
+		for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice {
+			typ = typ.Elem()
+		}
+		if typ.Kind() == reflect.Struct {
+			if f, ok := typ.FieldByName(part); ok {
+				if tag := f.Tag.Get(tagName); tag != "" {
+					name = strings.Split(tag, ",")[0] // берём до запятой
 				}
+				typ = f.Type
 			}
-			ctx.JSON(http.StatusBadRequest, gin.H{"errors": out})
-			return false
 		}
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
-		return false
+		names = append(names, name)
 	}
-	return true
+	return strings.Join(names, ".")
 }
@@ -0,0 +1,233 @@
+package importer
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/apierror"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/helpers"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/repository"
+)
+
+// batchSize is the number of rows checked for duplicates and created at once.
+const batchSize = 1000
+
+// ErrUnreadable is returned when the rest of a file cannot be read, such as
+// after a line too long.
+var ErrUnreadable = errors.New("could not read the file")
+
+// Statuses of the rows that were not imported.
+const (
+	StatusDuplicate = "duplicate"
+	StatusFailed    = "failed"
+)
+
+// Report is the outcome of an import.
+// @Description Outcome of an import, listing the rows that were not imported
+type Report struct {
+	// Whether nothing was saved and Imported counts the rows that would have been
+	// example: false
+	DryRun bool `json:"dry_run"`
+	// Number of rows read
+	// example: 3
+	Total int `json:"total"`
+	// Number of rows imported
+	// example: 1
+	Imported int `json:"imported"`
+	// Number of rows skipped as duplicates
+	// example: 1
+	Duplicates int `json:"duplicates"`
+	// Number of invalid rows
+	// example: 1
+	Failed int `json:"failed"`
+	// Rows that were skipped as duplicates or failed, in the order of the file
+	Rows []RowReport `json:"rows"`
+}
+
+// RowReport is the outcome of a row that was not imported.
+type RowReport struct {
+	// Line of the file the row starts at
+	// example: 2
+	Line int `json:"line"`
+	// Outcome of the row: duplicate or failed
+	// example: "duplicate"
+	Status string `json:"status"`
+	// ID of the stored subscription the row duplicates
+	// example: 1
+	DuplicateOf int64 `json:"duplicate_of,omitempty"`
+	// Line of an earlier row of the file the row duplicates
+	// example: 1
+	DuplicateOfLine int `json:"duplicate_of_line,omitempty"`
+	// Problem of a failed row
+	Error *apierror.Problem `json:"error,omitempty"`
+}
+
+// Options control an import.
+type Options struct {
+	// DryRun checks the rows without saving any.
+	DryRun bool
+	// Audit is who the subscriptions are created by.
+	Audit models.AuditInfo
+}
+
+// Importer creates subscriptions from the rows of a file. Each row is
+// validated like a subscription sent to POST /api/v1/subscriptions; rows that
+// fail validation or duplicate a stored subscription or an earlier row are
+// skipped and reported.
+type Importer struct {
+	subs     repository.SubscriptionRepository
+	services repository.ServiceRepository
+}
+
+func New(subs repository.SubscriptionRepository, services repository.ServiceRepository) *Importer {
+	return &Importer{subs: subs, services: services}
+}
+
+// pending is a valid row waiting to be checked for duplicates and created.
+type pending struct {
+	line int
+	sub  *models.Subscription
+}
+
+// Import reads all rows and creates the subscriptions they hold in batches,
+// each in its own transaction. When a batch cannot be saved the report of the
+// rows read so far is returned with the error; earlier batches stay imported.
+// The same holds for a file that cannot be read to its end.
+func (im *Importer) Import(ctx context.Context, rows RowReader, opts Options) (*Report, error) {
+	report := &Report{DryRun: opts.DryRun, Rows: []RowReport{}}
+	seen := make(map[models.DuplicateKey]int)
+	services := make(map[serviceKey]serviceResult)
+	batch := make([]pending, 0, batchSize)
+	defer report.sort()
+	last := 0
+	for {
+		row, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return report, fmt.Errorf("%w after line %d: %v", ErrUnreadable, last, err)
+		}
+		last = row.Line
+		report.Total++
+		s, problem, err := im.decode(ctx, row, services)
+		if err != nil {
+			return report, err
+		}
+		if problem != nil {
+			p := problem.Problem("")
+			report.add(RowReport{Line: row.Line, Status: StatusFailed, Error: &p})
+			continue
+		}
+		key := s.DuplicateKey()
+		if line, ok := seen[key]; ok {
+			report.add(RowReport{Line: row.Line, Status: StatusDuplicate, DuplicateOfLine: line})
+			continue
+		}
+		seen[key] = row.Line
+		batch = append(batch, pending{line: row.Line, sub: s})
+		if len(batch) == batchSize {
+			if err := im.flush(ctx, batch, report, opts); err != nil {
+				return report, err
+			}
+			batch = batch[:0]
+		}
+	}
+	return report, im.flush(ctx, batch, report, opts)
+}
+
+// decode turns a row into a valid subscription with its service resolved, or
+// the problem of the row. err reports a failure of the import as a whole.
+func (im *Importer) decode(ctx context.Context, row Row, services map[serviceKey]serviceResult) (*models.Subscription, *apierror.Error, error) {
+	if row.Err != nil {
+		return nil, apierror.BadRequest(row.Err.Error()), nil
+	}
+	if !json.Valid(row.Data) {
+		return nil, apierror.BadRequest("row is not valid JSON"), nil
+	}
+	var s models.Subscription
+	if err := json.Unmarshal(row.Data, &s); err != nil {
+		return nil, apierror.BadRequest(err.Error()), nil
+	}
+	if problem := helpers.Validate(&s); problem != nil {
+		return nil, problem, nil
+	}
+	k := serviceKey{s.ServiceId, s.ServiceName}
+	r, ok := services[k]
+	if !ok {
+		r.service, r.err = repository.FindService(ctx, im.services, s.ServiceId, s.ServiceName)
+		services[k] = r
+	}
+	if errors.Is(r.err, models.ErrUnknownService) {
+		return nil, apierror.Unprocessable(r.err), nil
+	} else if r.err != nil {
+		return nil, nil, r.err
+	}
+	s.ServiceId, s.ServiceName = r.service.Id, r.service.Name
+	return &s, nil, nil
+}
+
+// serviceKey and serviceResult memoize the services of an import, as the rows
+// of a file usually name few distinct services.
+type serviceKey struct {
+	id   int64
+	name string
+}
+
+type serviceResult struct {
+	service *models.Service
+	err     error
+}
+
+// flush skips the rows of batch duplicating stored subscriptions and creates
+// the others.
+func (im *Importer) flush(ctx context.Context, batch []pending, report *Report, opts Options) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	subs := make([]*models.Subscription, len(batch))
+	for i, p := range batch {
+		subs[i] = p.sub
+	}
+	duplicates, err := im.subs.FindDuplicates(ctx, subs)
+	if err != nil {
+		return err
+	}
+	create := make([]*models.Subscription, 0, len(subs))
+	for i, p := range batch {
+		if duplicates[i] != 0 {
+			report.add(RowReport{Line: p.line, Status: StatusDuplicate, DuplicateOf: duplicates[i]})
+			continue
+		}
+		create = append(create, p.sub)
+	}
+	if !opts.DryRun {
+		if err := im.subs.CreateMany(ctx, create, opts.Audit); err != nil {
+			return err
+		}
+	}
+	report.Imported += len(create)
+	return nil
+}
+
+func (r *Report) add(row RowReport) {
+	switch row.Status {
+	case StatusDuplicate:
+		r.Duplicates++
+	case StatusFailed:
+		r.Failed++
+	}
+	r.Rows = append(r.Rows, row)
+}
+
+// sort orders the rows by line, as duplicates of stored subscriptions are only
+// found once their batch is complete.
+func (r *Report) sort() {
+	slices.SortStableFunc(r.Rows, func(a, b RowReport) int { return cmp.Compare(a.Line, b.Line) })
+}
@@ -0,0 +1,189 @@
+// Package importer creates subscriptions from CSV and NDJSON files.
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Format is a file format subscriptions are imported from.
+type Format string
+
+const (
+	// FormatCSV is CSV with a header naming the columns, see CSVColumns.
+	FormatCSV Format = "csv"
+	// FormatNDJSON is one subscription per line in its JSON shape.
+	FormatNDJSON Format = "ndjson"
+)
+
+// ParseFormat returns the format named s.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case FormatCSV, FormatNDJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected %s or %s", s, FormatCSV, FormatNDJSON)
+}
+
+// FormatOf returns the format of a file by its extension.
+func FormatOf(name string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON, nil
+	}
+	return "", fmt.Errorf("cannot tell the format of %q, expected a .csv, .ndjson or .jsonl file", name)
+}
+
+// CSVColumns are the columns a CSV file may have, named after the JSON fields
+// of a subscription with the fields of its price joined by a dot. The columns
+// may come in any order and optional ones may be left out; id is accepted so
+// that exported files can be imported again, but new ids are assigned.
+var CSVColumns = []string{
+	"id", "service_id", "service_name", "monthly_price.amount", "monthly_price.currency",
+	"user_id", "start_date", "end_date",
+}
+
+// numericColumns are the CSV columns holding JSON numbers.
+var numericColumns = map[string]bool{"id": true, "service_id": true, "monthly_price.amount": true}
+
+// Row is one subscription of an imported file in its JSON shape, or the error
+// that kept the line from being read.
+type Row struct {
+	// Line is the line of the file the row starts at, counting from 1.
+	Line int
+	Data json.RawMessage
+	Err  error
+}
+
+// RowReader reads the rows of a file one by one. Next returns io.EOF after
+// the last row; any other error means the rest of the file cannot be read.
+type RowReader interface {
+	Next() (Row, error)
+}
+
+// NewReader returns a reader of the rows of r in format f. It fails when a CSV
+// header is missing or names unknown columns.
+func NewReader(r io.Reader, f Format) (RowReader, error) {
+	switch f {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatNDJSON:
+		return newNDJSONReader(r), nil
+	}
+	return nil, fmt.Errorf("unknown format %q", f)
+}
+
+type csvReader struct {
+	reader  *csv.Reader
+	columns []string
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("csv is empty")
+		}
+		return nil, err
+	}
+	known := make(map[string]bool, len(CSVColumns))
+	for _, column := range CSVColumns {
+		known[column] = true
+	}
+	columns := make([]string, len(header))
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		column := strings.ToLower(strings.TrimSpace(name))
+		if !known[column] {
+			return nil, fmt.Errorf("unknown csv column %q, expected some of %s", name, strings.Join(CSVColumns, ","))
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("csv column %q is given twice", name)
+		}
+		seen[column] = true
+		columns[i] = column
+	}
+	reader.FieldsPerRecord = len(columns)
+	return &csvReader{reader: reader, columns: columns}, nil
+}
+
+// Next converts a record into the JSON of a subscription, leaving out empty
+// cells so that they count as missing.
+func (r *csvReader) Next() (Row, error) {
+	record, err := r.reader.Read()
+	var perr *csv.ParseError
+	if errors.As(err, &perr) {
+		return Row{Line: perr.StartLine, Err: perr.Err}, nil
+	}
+	if err != nil {
+		return Row{}, err
+	}
+	// FieldPos only knows the record of a successful Read
+	line, _ := r.reader.FieldPos(0)
+	obj := make(map[string]any)
+	for i, cell := range record {
+		cell = strings.TrimSpace(cell)
+		if cell == "" {
+			continue
+		}
+		var value any = cell
+		if _, err := strconv.ParseInt(cell, 10, 64); err == nil && numericColumns[r.columns[i]] {
+			value = json.Number(cell)
+		}
+		parent, name, nested := strings.Cut(r.columns[i], ".")
+		if !nested {
+			obj[parent] = value
+			continue
+		}
+		fields, _ := obj[parent].(map[string]any)
+		if fields == nil {
+			fields = make(map[string]any)
+			obj[parent] = fields
+		}
+		fields[name] = value
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return Row{Line: line, Err: err}, nil
+	}
+	return Row{Line: line, Data: data}, nil
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newNDJSONReader(r io.Reader) *ndjsonReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	return &ndjsonReader{scanner: scanner}
+}
+
+// Next skips blank lines. Lines that are not JSON are returned as they are and
+// fail as rows, not as the whole file.
+func (r *ndjsonReader) Next() (Row, error) {
+	for r.scanner.Scan() {
+		r.line++
+		data := bytes.TrimSpace(r.scanner.Bytes())
+		if len(data) > 0 {
+			return Row{Line: r.line, Data: bytes.Clone(data)}, nil
+		}
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Row{}, err
+	}
+	return Row{}, io.EOF
+}
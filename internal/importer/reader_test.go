@@ -0,0 +1,92 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, r RowReader) []Row {
+	t.Helper()
+	var rows []Row
+	for {
+		row, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return rows
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestCSVReaderMalformedRows(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		// lines of the rows read and the errors they fail with, nil for rows
+		// that were read
+		lines []int
+		errs  []error
+	}{
+		{
+			name:  "bare quote",
+			csv:   "service_name,monthly_price.amount\nNetflix,100\nx\"y,2\nSpotify,200\n",
+			lines: []int{2, 3, 4},
+			errs:  []error{nil, csv.ErrBareQuote, nil},
+		},
+		{
+			name:  "unterminated quote",
+			csv:   "service_name,monthly_price.amount\nNetflix,100\n\"Spotify,200\nYandex,300\n",
+			lines: []int{2, 3},
+			errs:  []error{nil, csv.ErrQuote},
+		},
+		{
+			name:  "wrong number of fields",
+			csv:   "service_name,monthly_price.amount\nNetflix\nSpotify,200\n",
+			lines: []int{2, 3},
+			errs:  []error{csv.ErrFieldCount, nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(strings.NewReader(tt.csv), FormatCSV)
+			if err != nil {
+				t.Fatalf("NewReader() error = %v", err)
+			}
+			rows := readAll(t, r)
+			if len(rows) != len(tt.lines) {
+				t.Fatalf("read %d rows, want %d: %+v", len(rows), len(tt.lines), rows)
+			}
+			for i, row := range rows {
+				if row.Line != tt.lines[i] {
+					t.Errorf("row %d: line = %d, want %d", i, row.Line, tt.lines[i])
+				}
+				if !errors.Is(row.Err, tt.errs[i]) {
+					t.Errorf("row %d: error = %v, want %v", i, row.Err, tt.errs[i])
+				}
+				if row.Err == nil && len(row.Data) == 0 {
+					t.Errorf("row %d: no data", i)
+				}
+			}
+		})
+	}
+}
+
+func TestCSVReaderRow(t *testing.T) {
+	r, err := NewReader(strings.NewReader("Service_Name, monthly_price.amount,monthly_price.currency,end_date\nNetflix,100,RUB,\n"), FormatCSV)
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	rows := readAll(t, r)
+	if len(rows) != 1 {
+		t.Fatalf("read %d rows, want 1", len(rows))
+	}
+	want := `{"monthly_price":{"amount":100,"currency":"RUB"},"service_name":"Netflix"}`
+	if got := string(rows[0].Data); got != want {
+		t.Errorf("Data = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,41 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/config"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/logger"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/repository"
+)
+
+// RunPurge permanently removes the subscriptions deleted longer than
+// cfg.Retention ago, once every cfg.Interval, until ctx is done.
+func RunPurge(ctx context.Context, repo repository.SubscriptionRepository, cfg config.PurgeConfig) {
+	if cfg.Interval <= 0 {
+		logger.Log.Info("purge job disabled")
+		return
+	}
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	for {
+		purge(ctx, repo, cfg.Retention)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func purge(ctx context.Context, repo repository.SubscriptionRepository, retention time.Duration) {
+	purged, err := repo.Purge(ctx, time.Now().Add(-retention))
+	if err != nil {
+		logger.Log.Error("failed to purge deleted subscriptions", slog.Any("err", err))
+		return
+	}
+	if purged > 0 {
+		logger.Log.Info("purged deleted subscriptions", slog.Int64("count", purged))
+	}
+}
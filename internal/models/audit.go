@@ -0,0 +1,67 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+)
+
+// SystemActor is the actor of the changes made by background jobs.
+const SystemActor = "system"
+
+// AuditInfo identifies who made a change and in which request.
+type AuditInfo struct {
+	Actor     string
+	RequestId string
+}
+
+// AuditEntry is one change of a subscription with snapshots of it before and after.
+// @Description A recorded change of a subscription
+type AuditEntry struct {
+	// ID of the entry
+	// example: 1
+	Id int64 `json:"id"`
+	// ID of the changed subscription
+	// example: 1
+	SubscriptionId int64 `json:"subscription_id"`
+	// Kind of change: create, update, delete, restore or purge
+	// example: "update"
+	Action string `json:"action"`
+	// Who made the change
+	// example: "alice"
+	Actor string `json:"actor"`
+	// ID of the request that made the change
+	// example: "0b9c4f0e-3c5e-4d8f-9a4a-2f1e5d6c7b8a"
+	RequestId string `json:"request_id"`
+	// Subscription before the change, null when it was created
+	Before json.RawMessage `json:"before" swaggertype:"object"`
+	// Subscription after the change, null when it was purged
+	After json.RawMessage `json:"after" swaggertype:"object"`
+	// Time of the change
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// NewAuditEntry records action on the subscription with the given id. Either
+// snapshot may be nil.
+func NewAuditEntry(id int64, action string, info AuditInfo, before, after *Subscription) (AuditEntry, error) {
+	e := AuditEntry{SubscriptionId: id, Action: action, Actor: info.Actor, RequestId: info.RequestId, ChangedAt: time.Now()}
+	var err error
+	if before != nil {
+		if e.Before, err = json.Marshal(before); err != nil {
+			return AuditEntry{}, err
+		}
+	}
+	if after != nil {
+		if e.After, err = json.Marshal(after); err != nil {
+			return AuditEntry{}, err
+		}
+	}
+	return e, nil
+}
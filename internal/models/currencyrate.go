@@ -0,0 +1,117 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// CurrencyRate is the exchange rate of a currency pair for one month.
+// @Description Monthly exchange rate of a currency pair
+type CurrencyRate struct {
+	// ISO 4217 code of the currency being converted
+	// example: "USD"
+	Base string `json:"base" binding:"required,iso4217"`
+	// ISO 4217 code of the currency converted into
+	// example: "RUB"
+	Quote string `json:"quote" binding:"required,iso4217,nefield=Base"`
+	// Month the rate applies to
+	// example: "06-2025"
+	Month MonthYear `json:"month" binding:"required,monthyear"`
+	// Units of the quote currency per unit of the base currency, with up to 10 decimal places
+	// example: 90.5
+	Rate Rate `json:"rate" binding:"required,gt=0" swaggertype:"number"`
+}
+
+// rateScale is the number of decimal places of currency_rate.rate.
+const rateScale = 10
+
+// Rate is an exchange rate. It holds the exact decimal it was given as, so that
+// conversions do not pick up the rounding errors of floats.
+type Rate struct {
+	// r is never changed once set, so that copies of a Rate can share it
+	r *big.Rat
+}
+
+// ParseRate parses a rate written as a decimal number, such as "90.5" or "1e-3".
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || strings.Contains(s, "/") {
+		return Rate{}, fmt.Errorf("invalid rate %q", s)
+	}
+	return Rate{r: r}, nil
+}
+
+// Rat returns the value of the rate.
+func (r Rate) Rat() *big.Rat {
+	if r.r == nil {
+		return new(big.Rat)
+	}
+	return new(big.Rat).Set(r.r)
+}
+
+// Float64 returns the value of the rate as the nearest float.
+func (r Rate) Float64() float64 {
+	f, _ := r.Rat().Float64()
+	return f
+}
+
+// String formats the rate as a decimal of at most 10 places.
+func (r Rate) String() string {
+	s := r.Rat().FloatString(rateScale)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalJSON reads a rate from a JSON number.
+func (r *Rate) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	rate, err := ParseRate(string(b))
+	if err != nil {
+		return err
+	}
+	*r = rate
+	return nil
+}
+
+// Scan reads a rate from a NUMERIC column, which the driver returns as text.
+func (r *Rate) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		rate, err := ParseRate(string(v))
+		*r = rate
+		return err
+	case string:
+		rate, err := ParseRate(v)
+		*r = rate
+		return err
+	}
+	return fmt.Errorf("cannot scan %T into a rate", src)
+}
+
+func (r Rate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+// CurrencyRateFilter holds the query parameters accepted by the rate listing.
+// swagger:model CurrencyRateFilter
+type CurrencyRateFilter struct {
+	// Only rates with this base currency
+	// example: "USD"
+	Base string `form:"base" binding:"omitempty,iso4217"`
+	// Only rates with this quote currency
+	// example: "RUB"
+	Quote string `form:"quote" binding:"omitempty,iso4217"`
+	// Earliest month, inclusive
+	From *MonthYear `form:"from"`
+	// Latest month, inclusive
+	To *MonthYear `form:"to"`
+}
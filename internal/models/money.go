@@ -0,0 +1,74 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrCurrencyMismatch      = errors.New("amounts in different currencies cannot be summed without a target currency")
+	ErrConversionUnavailable = errors.New("currency conversion is not available")
+)
+
+// Money is an amount in minor units of a currency.
+// @Description Amount of money in minor units (e.g. kopecks, cents) of an ISO 4217 currency
+type Money struct {
+	// Amount in minor units of the currency
+	// example: 49900
+	Amount int64 `json:"amount"`
+	// ISO 4217 currency code
+	// example: "RUB"
+	Currency string `json:"currency" binding:"required,iso4217"`
+}
+
+// minorUnitExponents are the ISO 4217 currencies whose minor unit is not a
+// hundredth of the major one, by the number of decimals of the minor unit.
+var minorUnitExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0,
+	"RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// MinorUnitExponent returns the number of decimals of the minor unit of
+// currency: 2 for most currencies, 0 for JPY, 3 for KWD.
+func MinorUnitExponent(currency string) int {
+	if e, ok := minorUnitExponents[currency]; ok {
+		return e
+	}
+	return 2
+}
+
+// CurrencyConverter converts money into another currency at the rate in effect for month.
+type CurrencyConverter interface {
+	Convert(ctx context.Context, m Money, currency string, month MonthYear) (Money, error)
+}
+
+// Add returns the sum of m and o. A zero Money takes the currency of the other operand.
+func (m Money) Add(o Money) (Money, error) {
+	switch {
+	case m.Currency == "":
+		return Money{Amount: m.Amount + o.Amount, Currency: o.Currency}, nil
+	case o.Currency == "" || o.Currency == m.Currency:
+		return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+	}
+	return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+}
+
+// Times returns m multiplied by n.
+func (m Money) Times(n int64) Money {
+	return Money{Amount: m.Amount * n, Currency: m.Currency}
+}
+
+// ConvertMoney converts m into currency using conv. m is returned unchanged when
+// currency is empty or already matches.
+func ConvertMoney(ctx context.Context, m Money, currency string, month MonthYear, conv CurrencyConverter) (Money, error) {
+	if currency == "" || m.Currency == currency {
+		return m, nil
+	}
+	if conv == nil {
+		return Money{}, fmt.Errorf("%w: no rates configured for %s to %s", ErrConversionUnavailable, m.Currency, currency)
+	}
+	return conv.Convert(ctx, m, currency, month)
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"database/sql/driver"
 	"encoding/json"
 	"time"
 )
@@ -27,6 +28,11 @@ func (m MonthYear) MarshalJSON() ([]byte, error) {
 	return json.Marshal(t.Format(layoutMonthYear))
 }
 
+// String formats m as MM-YYYY.
+func (m MonthYear) String() string {
+	return time.Time(m).Format(layoutMonthYear)
+}
+
 func (m MonthYear) ToTime() time.Time {
 	return time.Time(m)
 }
@@ -34,3 +40,28 @@ func (m MonthYear) ToTime() time.Time {
 func FromTime(t time.Time) MonthYear {
 	return MonthYear(t)
 }
+
+// Value stores a MonthYear as a date. A nil *MonthYear is stored as NULL.
+func (m MonthYear) Value() (driver.Value, error) {
+	return time.Time(m), nil
+}
+
+// StartOfMonth returns the first day of the month of t.
+func StartOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// StartOfNextMonth returns the first day of the month following t.
+func StartOfNextMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// UnmarshalParam lets gin bind a MonthYear from a query or form parameter.
+func (m *MonthYear) UnmarshalParam(param string) error {
+	t, err := time.Parse(layoutMonthYear, param)
+	if err != nil {
+		return err
+	}
+	*m = MonthYear(t)
+	return nil
+}
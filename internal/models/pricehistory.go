@@ -0,0 +1,40 @@
+package models
+
+// PricePeriod is a monthly price of a subscription and the month it takes effect from.
+// @Description A monthly price in effect from a month until the next price period
+type PricePeriod struct {
+	// First month charged at the price
+	// example: "06-2025"
+	EffectiveFrom MonthYear `json:"effective_from"`
+	// Monthly price
+	MonthlyPrice Money `json:"monthly_price"`
+}
+
+// PriceAt returns the price in effect in month, given the price periods of a
+// subscription ordered by EffectiveFrom. Months before the first period, which
+// a later start_date change can leave uncovered, are charged at the first price.
+func PriceAt(periods []PricePeriod, month MonthYear) Money {
+	if len(periods) == 0 {
+		return Money{}
+	}
+	price := periods[0].MonthlyPrice
+	for _, p := range periods[1:] {
+		if p.EffectiveFrom.ToTime().After(month.ToTime()) {
+			break
+		}
+		price = p.MonthlyPrice
+	}
+	return price
+}
+
+// WithPrice returns periods with p in effect from p.EffectiveFrom onwards,
+// replacing the periods starting on or after that month.
+func WithPrice(periods []PricePeriod, p PricePeriod) []PricePeriod {
+	kept := []PricePeriod{}
+	for _, existing := range periods {
+		if existing.EffectiveFrom.ToTime().Before(p.EffectiveFrom.ToTime()) {
+			kept = append(kept, existing)
+		}
+	}
+	return append(kept, p)
+}
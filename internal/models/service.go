@@ -0,0 +1,59 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrUnknownService  = errors.New("service is not registered in the catalogue")
+	ErrServiceConflict = errors.New("service name or alias is already used by another service")
+	ErrServiceInUse    = errors.New("service is used by subscriptions")
+)
+
+// Service is an entry of the services catalogue.
+// @Description A service users can subscribe to
+type Service struct {
+	// ID of the service
+	// example: 1
+	Id int64 `json:"id"`
+	// Canonical name of the service
+	// example: "Netflix"
+	Name string `json:"name" binding:"required,notblank"`
+	// Alternative names resolving to this service, matched ignoring case and extra whitespace
+	// example: ["netflix premium"]
+	Aliases []string `json:"aliases"`
+	// Default monthly price of the service
+	DefaultPrice *Money `json:"default_price"`
+	// Category of the service
+	// example: "streaming"
+	Category string `json:"category"`
+}
+
+// ServiceKey normalises a service name or alias for matching: case and
+// surrounding or repeated whitespace are ignored.
+func ServiceKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// Normalize collapses whitespace in the name and turns the aliases into
+// distinct lookup keys that differ from the name.
+func (s *Service) Normalize() {
+	s.Name = strings.Join(strings.Fields(s.Name), " ")
+	seen := map[string]bool{ServiceKey(s.Name): true}
+	aliases := []string{}
+	for _, alias := range s.Aliases {
+		key := ServiceKey(alias)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		aliases = append(aliases, key)
+	}
+	s.Aliases = aliases
+}
+
+// Keys returns every lookup key of the service: its name and its aliases.
+func (s *Service) Keys() []string {
+	return append([]string{ServiceKey(s.Name)}, s.Aliases...)
+}
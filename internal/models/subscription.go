@@ -1,35 +1,45 @@
 package models
 
 import (
-	"database/sql"
-	"log/slog"
+	"errors"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/logger"
-	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/storage"
 )
 
+// ErrVersionConflict is returned when a subscription was changed since the
+// version an update was based on.
+var ErrVersionConflict = errors.New("subscription was changed by another request")
+
 // Subscription represents a user's subscription
 // @Description A subscription that a user has to a service
 type Subscription struct {
 	// ID of the subscription
 	// example: 1
 	Id int64 `json:"id"`
-	// Name of the service
+	// ID of the service in the catalogue. Either service_id or service_name is required
+	// example: 1
+	ServiceId int64 `json:"service_id"`
+	// Name or alias of the service, resolved through the catalogue to its canonical name
 	// example: "Netflix"
-	ServiceName string `json:"service_name" binding:"required"`
-	// Monthly price of the subscription
-	// example: 100
-	MonthlyPrice int32 `json:"monthly_price" binding:"required"`
+	ServiceName string `json:"service_name" binding:"required_without=ServiceId"`
+	// Latest monthly price of the subscription, a positive amount. Earlier months are charged at the
+	// prices recorded in its price history
+	MonthlyPrice Money `json:"monthly_price" binding:"required"`
 	// ID of the user who owns the subscription
 	// example: "f47ac10b-58cc-4372-a567-0e02b2c3d479"
-	UserId uuid.UUID `json:"user_id" binding:"required"`
+	UserId uuid.UUID `json:"user_id" binding:"required,uuid"`
 	// Start date of the subscription
 	// example: "06-2006"
-	StartDate MonthYear `json:"start_date" binding:"required"`
-	// End date of the subscription
+	StartDate MonthYear `json:"start_date" binding:"required,monthyear"`
+	// End date of the subscription, not before the start date
 	// example: "06-2006"
-	EndDate *MonthYear `json:"end_date"`
+	EndDate *MonthYear `json:"end_date" binding:"omitempty,monthyear"`
+	// Time the subscription was deleted, set only on deleted subscriptions
+	DeletedAt *time.Time `json:"deleted_at,omitempty" swaggerignore:"true"`
+	// Version of the subscription, increased by every change and sent as its ETag
+	// example: 1
+	Version int64 `json:"version" swaggerignore:"true"`
 }
 
 // UpdateSubscription represents data for updating a subscription
@@ -38,107 +48,43 @@ type UpdateSubscription struct {
 	// ID of the subscription to update
 	// example: 1
 	Id int64 `json:"id" binding:"required"`
-	// Name of the service
+	// Version the update is based on. Optional; when set, the update is rejected
+	// if the subscription has changed since
+	// example: 1
+	Version int64 `json:"version"`
+	// ID of the service in the catalogue
+	// example: 1
+	ServiceId int64 `json:"service_id"`
+	// Name or alias of the service, resolved through the catalogue
 	// example: "Netflix"
 	ServiceName string `json:"service_name"`
-	// Monthly price of the subscription
-	// example: 100
-	MonthlyPrice int32 `json:"monthly_price"`
+	// New monthly price of the subscription
+	MonthlyPrice *Money `json:"monthly_price"`
+	// Month the new monthly price takes effect from. Defaults to the current month;
+	// earlier months keep being charged at the prices in effect then
+	// example: "06-2006"
+	PriceEffectiveFrom *MonthYear `json:"price_effective_from" binding:"omitempty,monthyear"`
 	// ID of the user
 	// example: "f47ac10b-58cc-4372-a567-0e02b2c3d479"
-	UserId uuid.UUID `json:"user_id"`
+	UserId uuid.UUID `json:"user_id" binding:"omitempty,uuid"`
 	// Start date of the subscription
 	// example: "06-2006"
-	StartDate *MonthYear `json:"start_date"`
+	StartDate *MonthYear `json:"start_date" binding:"omitempty,monthyear"`
 	// End date of the subscription
 	// example: "06-2006"
-	EndDate *MonthYear `json:"end_date"`
-}
-
-func GetById(id int64) (*Subscription, error) {
-	row := storage.DB.QueryRow(
-		`SELECT id, service_name, monthly_price, user_id, start_date, end_date
-		 FROM subscription WHERE id = $1`, id)
-
-	var s Subscription
-	err := row.Scan(&s.Id, &s.ServiceName, &s.MonthlyPrice, &s.UserId, &s.StartDate, &s.EndDate)
-	if err == sql.ErrNoRows {
-		return nil, sql.ErrNoRows
-	}
-	if err != nil {
-		logger.Log.Error("failed to get subscription by id", slog.Any("err", err))
-		return nil, err
-	}
-	return &s, nil
-}
-
-func GetAll() ([]Subscription, error) {
-	rows, err := storage.DB.Query(
-		`SELECT id, service_name, monthly_price, user_id, start_date, end_date FROM subscription`)
-	if err != nil {
-		logger.Log.Error("failed to get subscriptions", slog.Any("err", err))
-		return nil, err
-	}
-	defer rows.Close()
-
-	var subscriptions []Subscription
-	for rows.Next() {
-		var s Subscription
-		err := rows.Scan(&s.Id, &s.ServiceName, &s.MonthlyPrice, &s.UserId, &s.StartDate, &s.EndDate)
-		if err != nil {
-			logger.Log.Error("failed to scan subscription row", slog.Any("err", err))
-			return nil, err
-		}
-		subscriptions = append(subscriptions, s)
-	}
-	return subscriptions, nil
-}
-
-func (s *Subscription) Create() error {
-	query := `
-		INSERT INTO subscription (id, service_name, monthly_price, user_id, start_date, end_date)
-		VALUES (nextval('subscription_seq'), $1, $2, $3, $4, $5) RETURNING id`
-	err := storage.DB.QueryRow(query,
-		s.ServiceName, s.MonthlyPrice, s.UserId, s.StartDate.ToTime(), s.EndDate.ToTime()).
-		Scan(&s.Id)
-	if err != nil {
-		logger.Log.Error("failed to create subscription", slog.Any("err", err))
-		return err
-	}
-	return nil
+	EndDate *MonthYear `json:"end_date" binding:"omitempty,monthyear"`
 }
 
-func (req *UpdateSubscription) Update() error {
-	s, err := GetById(req.Id)
-	if err != nil {
-		return err
+// CompareAndUpdate copies every non-zero field of from onto to.
+func (to *Subscription) CompareAndUpdate(from *UpdateSubscription) {
+	if from.ServiceId != 0 {
+		to.ServiceId = from.ServiceId
 	}
-	s.compareAndUpdate(req)
-	query := `
-	UPDATE subscription 
-	SET service_name = $1, monthly_price = $2, user_id = $3, start_date = $4, end_date = $5 
-	WHERE id = $6`
-	stmt, err := storage.DB.Prepare(query)
-	if err != nil {
-		logger.Log.Error("failed to prepare update statement", slog.Any("err", err))
-		return err
-	}
-	defer stmt.Close()
-	_, err = stmt.Exec(s.ServiceName, s.MonthlyPrice, s.UserId, s.StartDate.ToTime(), s.EndDate.ToTime(), s.Id)
-	if err != nil {
-		logger.Log.Error("failed to execute update", slog.Any("err", err))
-		return err
-	}
-	logger.Log.Info("updated subscription", slog.Any("id", s.Id))
-	return nil
-}
-
-func (to *Subscription) compareAndUpdate(from *UpdateSubscription) {
 	if from.ServiceName != "" {
 		to.ServiceName = from.ServiceName
 	}
-	if from.MonthlyPrice != 0 {
-		to.MonthlyPrice = from.MonthlyPrice
+	if from.MonthlyPrice != nil {
+		to.MonthlyPrice = *from.MonthlyPrice
 	}
 	if from.UserId != uuid.Nil {
 		to.UserId = from.UserId
@@ -151,28 +97,38 @@ func (to *Subscription) compareAndUpdate(from *UpdateSubscription) {
 	}
 }
 
-func Delete(id int64) error {
-	query := `DELETE FROM subscription WHERE id = $1`
-	stmt, err := storage.DB.Prepare(query)
-	if err != nil {
-		logger.Log.Error("failed to prepare delete statement", slog.Any("id", id), slog.Any("err", err))
-		return err
-	}
-	defer stmt.Close()
-	res, err := stmt.Exec(id)
-	if err != nil {
-		logger.Log.Error("failed to execute delete", slog.Any("id", id), slog.Any("err", err))
-		return err
-	}
-	deleted, err := res.RowsAffected()
-	if err != nil {
-		logger.Log.Error("failed to get rows affected for delete", slog.Any("id", id), slog.Any("err", err))
-		return err
-	}
-	if deleted == 0 {
-		logger.Log.Warn("no record deleted", slog.Any("id", id))
-		return sql.ErrNoRows
-	}
-	logger.Log.Info("deleted subscription", slog.Any("id", id))
-	return nil
+// Patch expresses the update as a change of the current subscription.
+func (from *UpdateSubscription) Patch() *SubscriptionPatch {
+	p := &SubscriptionPatch{
+		Version: from.Version,
+		Apply: func(s *Subscription) error {
+			s.CompareAndUpdate(from)
+			return nil
+		},
+	}
+	if from.MonthlyPrice != nil {
+		p.PriceEffectiveFrom = from.PriceEffectiveFrom
+	}
+	return p
+}
+
+// DuplicateKey identifies the subscriptions that duplicate each other: those
+// of the same user and service, at the same monthly price and with the same
+// dates.
+type DuplicateKey struct {
+	UserId    uuid.UUID
+	ServiceId int64
+	Price     Money
+	StartDate string
+	EndDate   string
+}
+
+// DuplicateKey returns the key s is compared with other subscriptions by.
+func (s *Subscription) DuplicateKey() DuplicateKey {
+	k := DuplicateKey{UserId: s.UserId, ServiceId: s.ServiceId, Price: s.MonthlyPrice,
+		StartDate: s.StartDate.ToTime().Format(layoutMonthYear)}
+	if s.EndDate != nil {
+		k.EndDate = s.EndDate.ToTime().Format(layoutMonthYear)
+	}
+	return k
 }
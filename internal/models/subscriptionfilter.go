@@ -0,0 +1,141 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+const DefaultPageLimit = 20
+
+// SubscriptionFilter holds the query parameters accepted by the subscription listing.
+// swagger:model SubscriptionFilter
+type SubscriptionFilter struct {
+	// Only subscriptions of this user
+	// example: "f47ac10b-58cc-4372-a567-0e02b2c3d479"
+	UserId string `form:"user_id" binding:"omitempty,uuid"`
+	// Only subscriptions to this service
+	// example: 1
+	ServiceId int64 `form:"service_id"`
+	// Only subscriptions to this service, resolved through the catalogue to service_id
+	// example: "Netflix"
+	ServiceName string `form:"service_name"`
+	// Only subscriptions priced in this currency
+	// example: "RUB"
+	Currency string `form:"currency" binding:"omitempty,iso4217"`
+	// Lower bound of the monthly price in minor units, inclusive
+	MinPrice *int64 `form:"min_price"`
+	// Upper bound of the monthly price in minor units, inclusive
+	MaxPrice *int64 `form:"max_price"`
+	// Only subscriptions active in this month
+	// example: "06-2025"
+	ActiveAt *MonthYear `form:"active_at"`
+	// Start date range, inclusive
+	StartFrom *MonthYear `form:"start_from"`
+	StartTo   *MonthYear `form:"start_to"`
+	// End date range, inclusive
+	EndFrom *MonthYear `form:"end_from"`
+	EndTo   *MonthYear `form:"end_to"`
+	// Column to sort by
+	SortBy string `form:"sort_by" binding:"omitempty,oneof=id service_name monthly_price start_date end_date"`
+	// Sort direction
+	Order string `form:"order" binding:"omitempty,oneof=asc desc"`
+	// next_cursor of the previous page (keyset pagination)
+	Cursor string `form:"cursor"`
+	// After is the subscription Cursor points after, set by ParseCursor
+	After *Subscription `form:"-"`
+	// Page size
+	Limit int `form:"limit" binding:"omitempty,min=1,max=100"`
+	// Number of rows to skip (offset pagination)
+	Offset int `form:"offset" binding:"omitempty,min=0"`
+	// Whether deleted subscriptions are listed too
+	IncludeDeleted bool `form:"include_deleted"`
+}
+
+// SubscriptionPage is a single page of the subscription listing.
+// @Description A page of subscriptions with pagination metadata
+type SubscriptionPage struct {
+	// Subscriptions on this page
+	Items []Subscription `json:"items"`
+	// Cursor to pass to get the next page, null on the last page
+	// example: eyJzIjoiaWQiLCJpIjoyMH0
+	NextCursor *string `json:"next_cursor"`
+	// Number of subscriptions matching the filters
+	// example: 42
+	TotalCount int64 `json:"total_count"`
+}
+
+// SetDefaults fills in the sort and page size when they were not provided.
+func (f *SubscriptionFilter) SetDefaults() {
+	if f.SortBy == "" {
+		f.SortBy = "id"
+	}
+	if f.Order == "" {
+		f.Order = "asc"
+	}
+	if f.Limit == 0 {
+		f.Limit = DefaultPageLimit
+	}
+}
+
+// ErrInvalidCursor is a cursor that was not returned by a listing sorted by the
+// same column.
+var ErrInvalidCursor = errors.New("cursor is invalid or belongs to another sort column")
+
+// pageCursor is the content of a cursor: the sort value and id of the last
+// subscription of a page. It holds the values themselves rather than refer to
+// the subscription, so that it stays valid when the subscription is deleted.
+type pageCursor struct {
+	SortBy       string     `json:"s"`
+	Id           int64      `json:"i"`
+	ServiceName  string     `json:"n,omitempty"`
+	MonthlyPrice int64      `json:"p,omitempty"`
+	Date         *MonthYear `json:"d,omitempty"`
+}
+
+// NextCursor returns the cursor of the page ending with s.
+func (f *SubscriptionFilter) NextCursor(s Subscription) *string {
+	c := pageCursor{SortBy: f.SortBy, Id: s.Id}
+	switch f.SortBy {
+	case "service_name":
+		c.ServiceName = s.ServiceName
+	case "monthly_price":
+		c.MonthlyPrice = s.MonthlyPrice.Amount
+	case "start_date":
+		c.Date = &s.StartDate
+	case "end_date":
+		c.Date = s.EndDate
+	}
+	b, _ := json.Marshal(c)
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return &token
+}
+
+// ParseCursor sets After from Cursor, with the id and the value of the sort
+// column of the subscription it points after. It must be called after
+// SetDefaults.
+func (f *SubscriptionFilter) ParseCursor() error {
+	if f.Cursor == "" {
+		return nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(f.Cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	var c pageCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.SortBy != f.SortBy {
+		return ErrInvalidCursor
+	}
+	after := &Subscription{Id: c.Id, ServiceName: c.ServiceName, MonthlyPrice: Money{Amount: c.MonthlyPrice}}
+	switch c.SortBy {
+	case "start_date":
+		if c.Date == nil {
+			return ErrInvalidCursor
+		}
+		after.StartDate = *c.Date
+	case "end_date":
+		after.EndDate = c.Date
+	}
+	f.After = after
+	return nil
+}
@@ -1,60 +1,260 @@
 package models
 
 import (
-	"log/slog"
+	"context"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/logger"
-	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/storage"
 )
 
+// MaxInvoiceMonths is the longest period, in months, an invoice may cover.
+const MaxInvoiceMonths = 120
+
 // SubscriptionInvoiceRequest represents a request to calculate the total cost of subscriptions.
 // swagger:model SubscriptionInvoiceRequest
 type SubscriptionInvoiceRequest struct {
-	// Service name
+	// Service ID. Optional, all services are included when empty
+	// example: 1
+	ServiceId int64 `json:"service_id"`
+
+	// Service name or alias, resolved through the catalogue to service_id. Optional
 	// example: "Netflix"
-	ServiceName string `json:"service_name" binding:"required"`
+	ServiceName string `json:"service_name"`
 
-	// Unique user identifier
+	// Unique user identifier. Optional, all users are included when empty
 	// example: "f47ac10b-58cc-4372-a567-0e02b2c3d479"
-	UserId uuid.UUID `json:"user_id" binding:"required"`
+	UserId uuid.UUID `json:"user_id" binding:"omitempty,uuid"`
 
 	// Start date of the period (month/year)
 	// example: "06-2006"
-	FromDate MonthYear `json:"from_date" binding:"required"`
+	FromDate MonthYear `json:"from_date" binding:"required,monthyear"`
 
-	// End date of the period (month/year). Can be null, in which case the current date is used
+	// End date of the period (month/year), not before from_date. Can be null, in which case the period runs through the current month. The period may span at most 120 months
 	// example: "06-2006"
-	ToDate *MonthYear `json:"to_date" binding:"required"`
-}
-
-func (f *SubscriptionInvoiceRequest) GetSubscriptionsInvoice() (int32, error) {
-	query := `
-	SELECT 
-		COALESCE(
-			SUM(
-				(DATE_PART('year', age(
-					LEAST(end_date, $2), 
-					GREATEST(start_date, $1)
-				)) * 12 
-				+ DATE_PART('month', age(
-					LEAST(end_date, $2), 
-					GREATEST(start_date, $1)
-				))
-				) * monthly_price
-			), 0
-		) AS total_cost
-	FROM subscription
-	WHERE service_name = $3
-	AND user_id = $4
-	AND start_date <= $2
-	AND end_date >= $1;
-	`
-	var invoice int32
-	err := storage.DB.QueryRow(query, f.FromDate.ToTime(), f.ToDate.ToTime(), f.ServiceName, f.UserId).Scan(&invoice)
-	if err != nil {
-		logger.Log.Error("failed to fetch subscriptions invoice", slog.Any("err", err))
-		return 0, err
+	ToDate *MonthYear `json:"to_date" binding:"omitempty,monthyear"`
+
+	// Dimension to group the totals by. Optional
+	// example: "service"
+	GroupBy string `json:"group_by" binding:"omitempty,oneof=service user month"`
+
+	// ISO 4217 currency to report the totals in. Optional, required only when
+	// the subscriptions in the period are priced in different currencies
+	// example: "RUB"
+	Currency string `json:"currency" binding:"omitempty,iso4217"`
+
+	// Whether deleted subscriptions are billed too
+	// example: false
+	IncludeDeleted bool `json:"include_deleted"`
+}
+
+// Period returns the bounds of the invoice window and the date open-ended
+// subscriptions are billed until. A null to_date and a null end_date both
+// mean "through the current month".
+func (f *SubscriptionInvoiceRequest) Period(now time.Time) (from, to, openEnd time.Time) {
+	openEnd = StartOfNextMonth(now)
+	from, to = f.FromDate.ToTime(), openEnd
+	if f.ToDate != nil {
+		to = f.ToDate.ToTime()
+	}
+	return from, to, openEnd
+}
+
+const (
+	GroupByService = "service"
+	GroupByUser    = "user"
+	GroupByMonth   = "month"
+)
+
+// SubscriptionInvoice is the total cost of subscriptions, optionally grouped.
+// @Description Invoice total with optional grouped totals
+type SubscriptionInvoice struct {
+	// Total cost of all subscriptions in the period
+	Sum Money `json:"sum"`
+	// Totals per group, present only when group_by is set
+	Groups []SubscriptionInvoiceGroup `json:"groups,omitempty"`
+}
+
+// SubscriptionInvoiceGroup is the total cost of one group of subscriptions.
+// Only the field matching group_by is set.
+// @Description Invoice total of one group
+type SubscriptionInvoiceGroup struct {
+	// Service name of the group
+	// example: "Netflix"
+	ServiceName string `json:"service_name,omitempty"`
+	// User of the group
+	// example: "f47ac10b-58cc-4372-a567-0e02b2c3d479"
+	UserId *uuid.UUID `json:"user_id,omitempty"`
+	// Month of the group
+	// example: "06-2025"
+	Month *MonthYear `json:"month,omitempty"`
+	// Total cost of the group
+	Sum Money `json:"sum"`
+}
+
+func (g SubscriptionInvoiceGroup) sameKey(o SubscriptionInvoiceGroup) bool {
+	return g.ServiceName == o.ServiceName &&
+		(g.UserId == nil) == (o.UserId == nil) && (g.UserId == nil || *g.UserId == *o.UserId) &&
+		(g.Month == nil) == (o.Month == nil) && (g.Month == nil || g.Month.ToTime().Equal(o.Month.ToTime()))
+}
+
+// InvoiceAmount is the amount billed for one invoice group in one currency.
+// Month is set when the amount was billed in a single calendar month.
+type InvoiceAmount struct {
+	Group  SubscriptionInvoiceGroup
+	Month  *MonthYear
+	Amount Money
+}
+
+// BilledPerMonth reports whether the invoice amounts have to be computed per
+// calendar month: to group by month, or to convert each month at its own rate.
+func (f *SubscriptionInvoiceRequest) BilledPerMonth() bool {
+	return f.GroupBy == GroupByMonth || f.Currency != ""
+}
+
+// NewSubscriptionInvoice totals the amounts billed per group and currency into a
+// single currency: the requested one, or the only one present when none was requested.
+// The amounts must be ordered by group.
+func NewSubscriptionInvoice(ctx context.Context, amounts []InvoiceAmount, req *SubscriptionInvoiceRequest, conv CurrencyConverter) (*SubscriptionInvoice, error) {
+	invoice := &SubscriptionInvoice{Sum: Money{Currency: req.Currency}}
+	if req.GroupBy != "" {
+		invoice.Groups = []SubscriptionInvoiceGroup{}
+	}
+	for _, a := range amounts {
+		var month MonthYear
+		if a.Month != nil {
+			month = *a.Month
+		}
+		amount, err := ConvertMoney(ctx, a.Amount, req.Currency, month, conv)
+		if err != nil {
+			return nil, err
+		}
+		if invoice.Sum, err = invoice.Sum.Add(amount); err != nil {
+			return nil, err
+		}
+		if req.GroupBy == "" {
+			continue
+		}
+		n := len(invoice.Groups)
+		if n == 0 || !invoice.Groups[n-1].sameKey(a.Group) {
+			g := a.Group
+			g.Sum = Money{Currency: amount.Currency}
+			invoice.Groups = append(invoice.Groups, g)
+			n++
+		}
+		group := &invoice.Groups[n-1]
+		if group.Sum, err = group.Sum.Add(amount); err != nil {
+			return nil, err
+		}
 	}
 	return invoice, nil
 }
+
+// SubscriptionInvoiceDetails is the invoice itemised per subscription and month.
+// @Description Invoice total with line items per subscription and month
+type SubscriptionInvoiceDetails struct {
+	// Total cost of all subscriptions in the period
+	Sum Money `json:"sum"`
+	// Line items per subscription
+	Subscriptions []SubscriptionInvoiceItem `json:"subscriptions"`
+}
+
+// SubscriptionInvoiceItem is the part of an invoice charged for a single subscription.
+// @Description Invoice line item for a single subscription
+type SubscriptionInvoiceItem struct {
+	// ID of the subscription
+	// example: 1
+	SubscriptionId int64 `json:"subscription_id"`
+	// ID of the service
+	// example: 1
+	ServiceId int64 `json:"service_id"`
+	// Name of the service
+	// example: "Netflix"
+	ServiceName string `json:"service_name"`
+	// Monthly price in effect in the last billed month
+	MonthlyPrice Money `json:"monthly_price"`
+	// Number of months billed in the period
+	// example: 3
+	MonthsBilled int `json:"months_billed"`
+	// Amount billed for the subscription in the period
+	Amount Money `json:"amount"`
+	// Amount billed per calendar month
+	Months []InvoiceMonth `json:"months"`
+}
+
+// InvoiceMonth is the amount billed for a subscription in one calendar month.
+// @Description Amount billed in one calendar month
+type InvoiceMonth struct {
+	// Billed month
+	// example: "06-2025"
+	Month MonthYear `json:"month"`
+	// Amount billed for the month
+	Amount Money `json:"amount"`
+}
+
+// AddMonth bills s for month at s.MonthlyPrice, the price in effect that month.
+// Months of the same subscription must be added consecutively and in order.
+func (d *SubscriptionInvoiceDetails) AddMonth(s Subscription, month MonthYear) {
+	n := len(d.Subscriptions)
+	if n == 0 || d.Subscriptions[n-1].SubscriptionId != s.Id {
+		d.Subscriptions = append(d.Subscriptions, SubscriptionInvoiceItem{
+			SubscriptionId: s.Id,
+			ServiceId:      s.ServiceId,
+			ServiceName:    s.ServiceName,
+			Months:         []InvoiceMonth{},
+		})
+		n++
+	}
+	item := &d.Subscriptions[n-1]
+	item.Months = append(item.Months, InvoiceMonth{Month: month, Amount: s.MonthlyPrice})
+	item.MonthlyPrice = s.MonthlyPrice
+	item.MonthsBilled++
+}
+
+// Total converts the billed months into currency, when set, and computes the
+// item amounts and the overall sum. Without a currency all months must share one.
+func (d *SubscriptionInvoiceDetails) Total(ctx context.Context, currency string, conv CurrencyConverter) error {
+	d.Sum = Money{Currency: currency}
+	for i := range d.Subscriptions {
+		item := &d.Subscriptions[i]
+		item.Amount = Money{Currency: currency}
+		for j := range item.Months {
+			m := &item.Months[j]
+			amount, err := ConvertMoney(ctx, m.Amount, currency, m.Month, conv)
+			if err != nil {
+				return err
+			}
+			m.Amount = amount
+			if item.Amount, err = item.Amount.Add(amount); err != nil {
+				return err
+			}
+		}
+		var err error
+		if d.Sum, err = d.Sum.Add(item.Amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InvoiceLine is the amount billed for one subscription in one calendar month,
+// a line of an exported invoice.
+// @Description Amount billed for one subscription in one calendar month
+type InvoiceLine struct {
+	// ID of the subscription
+	// example: 1
+	SubscriptionId int64 `json:"subscription_id"`
+	// ID of the service
+	// example: 1
+	ServiceId int64 `json:"service_id"`
+	// Name of the service
+	// example: "Netflix"
+	ServiceName string `json:"service_name"`
+	// ID of the user who owns the subscription
+	// example: "f47ac10b-58cc-4372-a567-0e02b2c3d479"
+	UserId uuid.UUID `json:"user_id"`
+	// Billed month
+	// example: "06-2025"
+	Month MonthYear `json:"month"`
+	// Amount billed for the month
+	Amount Money `json:"amount"`
+}
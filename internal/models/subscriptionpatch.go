@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SubscriptionPatch is a change applied to the current state of a subscription
+// while it is locked against concurrent changes.
+type SubscriptionPatch struct {
+	// Version the change is based on, 0 to apply it to any version
+	Version int64
+	// Month a new monthly price takes effect from, the current month when nil
+	PriceEffectiveFrom *MonthYear
+	// Apply changes the subscription in place
+	Apply func(s *Subscription) error
+}
+
+// CheckVersion rejects the change when it is based on a version other than current.
+func (p *SubscriptionPatch) CheckVersion(current int64) error {
+	if p.Version != 0 && p.Version != current {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// PriceChange returns the price period to record for the change from before to
+// after, or nil when the price stays. A price never takes effect before the
+// subscription starts.
+func (p *SubscriptionPatch) PriceChange(before, after *Subscription, now time.Time) *PricePeriod {
+	if after.MonthlyPrice == before.MonthlyPrice && p.PriceEffectiveFrom == nil {
+		return nil
+	}
+	effective := FromTime(StartOfMonth(now))
+	if p.PriceEffectiveFrom != nil {
+		effective = *p.PriceEffectiveFrom
+	}
+	if effective.ToTime().Before(after.StartDate.ToTime()) {
+		effective = after.StartDate
+	}
+	return &PricePeriod{EffectiveFrom: effective, MonthlyPrice: after.MonthlyPrice}
+}
+
+// SubscriptionDocument is the JSON document PATCH requests edit: the fields of
+// a subscription clients may change.
+// @Description Editable fields of a subscription
+type SubscriptionDocument struct {
+	// ID of the service in the catalogue
+	// example: 1
+	ServiceId int64 `json:"service_id"`
+	// Name or alias of the service
+	// example: "Netflix"
+	ServiceName string `json:"service_name" binding:"required_without=ServiceId"`
+	// Monthly price of the subscription, a positive amount
+	MonthlyPrice Money `json:"monthly_price"`
+	// ID of the user who owns the subscription
+	// example: "f47ac10b-58cc-4372-a567-0e02b2c3d479"
+	UserId uuid.UUID `json:"user_id" binding:"required,uuid"`
+	// Start date of the subscription
+	// example: "06-2006"
+	StartDate MonthYear `json:"start_date" binding:"required,monthyear"`
+	// End date of the subscription, null when it is open-ended
+	// example: "06-2006"
+	EndDate *MonthYear `json:"end_date" binding:"omitempty,monthyear"`
+}
+
+func NewSubscriptionDocument(s *Subscription) SubscriptionDocument {
+	return SubscriptionDocument{
+		ServiceId:    s.ServiceId,
+		ServiceName:  s.ServiceName,
+		MonthlyPrice: s.MonthlyPrice,
+		UserId:       s.UserId,
+		StartDate:    s.StartDate,
+		EndDate:      s.EndDate,
+	}
+}
+
+// ApplyTo sets every field of s to the document, zero values included.
+func (d *SubscriptionDocument) ApplyTo(s *Subscription) {
+	s.ServiceId = d.ServiceId
+	s.ServiceName = d.ServiceName
+	s.MonthlyPrice = d.MonthlyPrice
+	s.UserId = d.UserId
+	s.StartDate = d.StartDate
+	s.EndDate = d.EndDate
+}
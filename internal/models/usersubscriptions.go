@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserSubscriptions lists the subscriptions of a single user.
+// @Description Subscriptions of a user split into active and historical ones
+type UserSubscriptions struct {
+	// ID of the user
+	// example: "f47ac10b-58cc-4372-a567-0e02b2c3d479"
+	UserId uuid.UUID `json:"user_id"`
+	// Subscriptions that have not ended before the current month
+	Active []Subscription `json:"active"`
+	// Subscriptions that ended before the current month
+	Historical []Subscription `json:"historical"`
+}
+
+// NewUserSubscriptions splits subs into active and historical relative to the month of now.
+func NewUserSubscriptions(userId uuid.UUID, subs []Subscription, now time.Time) *UserSubscriptions {
+	currentMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	us := &UserSubscriptions{UserId: userId, Active: []Subscription{}, Historical: []Subscription{}}
+	for _, s := range subs {
+		if s.EndDate != nil && s.EndDate.ToTime().Before(currentMonth) {
+			us.Historical = append(us.Historical, s)
+		} else {
+			us.Active = append(us.Active, s)
+		}
+	}
+	return us
+}
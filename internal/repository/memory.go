@@ -0,0 +1,461 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+)
+
+// MemorySubscriptionRepository keeps subscriptions in process memory.
+// It is meant for tests and local experiments, not for production use.
+type MemorySubscriptionRepository struct {
+	mu            sync.RWMutex
+	seq           int64
+	subscriptions map[int64]models.Subscription
+	prices        map[int64][]models.PricePeriod
+	audit         []models.AuditEntry
+}
+
+func NewMemorySubscriptionRepository() *MemorySubscriptionRepository {
+	return &MemorySubscriptionRepository{
+		subscriptions: make(map[int64]models.Subscription),
+		prices:        make(map[int64][]models.PricePeriod),
+	}
+}
+
+func (r *MemorySubscriptionRepository) GetById(ctx context.Context, id int64, includeDeleted bool) (*models.Subscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.subscriptions[id]
+	if !ok || (s.DeletedAt != nil && !includeDeleted) {
+		return nil, sql.ErrNoRows
+	}
+	return &s, nil
+}
+
+func (r *MemorySubscriptionRepository) GetAll(ctx context.Context, f *models.SubscriptionFilter) (*models.SubscriptionPage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var matched []models.Subscription
+	for _, s := range r.subscriptions {
+		if matchesFilter(s, f) {
+			matched = append(matched, s)
+		}
+	}
+	less := func(a, b models.Subscription) bool {
+		c := compareSubscriptions(a, b, f.SortBy)
+		if f.Order == "desc" {
+			return c > 0
+		}
+		return c < 0
+	}
+	sort.Slice(matched, func(i, j int) bool { return less(matched[i], matched[j]) })
+
+	page := &models.SubscriptionPage{Items: []models.Subscription{}, TotalCount: int64(len(matched))}
+	if f.After != nil {
+		rest := matched[:0:0]
+		for _, s := range matched {
+			if less(*f.After, s) {
+				rest = append(rest, s)
+			}
+		}
+		matched = rest
+	}
+	if f.Offset >= len(matched) {
+		return page, nil
+	}
+	matched = matched[f.Offset:]
+	if len(matched) > f.Limit {
+		matched = matched[:f.Limit]
+		page.NextCursor = f.NextCursor(matched[f.Limit-1])
+	}
+	page.Items = append(page.Items, matched...)
+	return page, nil
+}
+
+func (r *MemorySubscriptionRepository) GetByUserId(ctx context.Context, userId uuid.UUID) ([]models.Subscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var subscriptions []models.Subscription
+	for _, s := range r.subscriptions {
+		if s.UserId == userId && s.DeletedAt == nil {
+			subscriptions = append(subscriptions, s)
+		}
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return compareSubscriptions(subscriptions[i], subscriptions[j], "start_date") < 0
+	})
+	return subscriptions, nil
+}
+
+func (r *MemorySubscriptionRepository) Create(ctx context.Context, s *models.Subscription, audit models.AuditInfo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s.Id = r.seq + 1
+	s.DeletedAt = nil
+	s.Version = 1
+	if err := r.record(s.Id, models.AuditCreate, audit, nil, s); err != nil {
+		return err
+	}
+	r.seq++
+	r.subscriptions[s.Id] = *s
+	r.prices[s.Id] = []models.PricePeriod{{EffectiveFrom: s.StartDate, MonthlyPrice: s.MonthlyPrice}}
+	return nil
+}
+
+func (r *MemorySubscriptionRepository) Patch(ctx context.Context, id int64, patch *models.SubscriptionPatch, audit models.AuditInfo) (*models.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	before, ok := r.subscriptions[id]
+	if !ok || before.DeletedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+	if err := patch.CheckVersion(before.Version); err != nil {
+		return nil, err
+	}
+	s := before
+	if err := patch.Apply(&s); err != nil {
+		return nil, err
+	}
+	s.Id = before.Id
+	s.Version++
+	if err := r.record(s.Id, models.AuditUpdate, audit, &before, &s); err != nil {
+		return nil, err
+	}
+	if change := patch.PriceChange(&before, &s, time.Now()); change != nil {
+		r.prices[s.Id] = models.WithPrice(r.prices[s.Id], *change)
+	}
+	r.subscriptions[s.Id] = s
+	return &s, nil
+}
+
+func (r *MemorySubscriptionRepository) Delete(ctx context.Context, id int64, audit models.AuditInfo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	before, ok := r.subscriptions[id]
+	if !ok || before.DeletedAt != nil {
+		return sql.ErrNoRows
+	}
+	s, now := before, time.Now()
+	s.DeletedAt = &now
+	s.Version++
+	if err := r.record(id, models.AuditDelete, audit, &before, &s); err != nil {
+		return err
+	}
+	r.subscriptions[id] = s
+	return nil
+}
+
+func (r *MemorySubscriptionRepository) CreateMany(ctx context.Context, subs []*models.Subscription, audit models.AuditInfo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	undo := r.snapshot()
+	for _, s := range subs {
+		s.Id = r.seq + 1
+		s.DeletedAt = nil
+		s.Version = 1
+		if err := r.record(s.Id, models.AuditCreate, audit, nil, s); err != nil {
+			undo()
+			return err
+		}
+		r.seq++
+		r.subscriptions[s.Id] = *s
+		r.prices[s.Id] = []models.PricePeriod{{EffectiveFrom: s.StartDate, MonthlyPrice: s.MonthlyPrice}}
+	}
+	return nil
+}
+
+func (r *MemorySubscriptionRepository) PatchMany(ctx context.Context, items []PatchItem, audit models.AuditInfo, atomic bool) ([]*models.Subscription, []error, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	undo := r.snapshot()
+	updated, errs := make([]*models.Subscription, len(items)), make([]error, len(items))
+	now := time.Now()
+	for i, item := range items {
+		before, ok := r.subscriptions[item.Id]
+		if !ok || before.DeletedAt != nil {
+			errs[i] = sql.ErrNoRows
+			continue
+		}
+		if errs[i] = item.Patch.CheckVersion(before.Version); errs[i] != nil {
+			continue
+		}
+		s := before
+		if errs[i] = item.Patch.Apply(&s); errs[i] != nil {
+			continue
+		}
+		s.Id = before.Id
+		s.Version++
+		if err := r.record(s.Id, models.AuditUpdate, audit, &before, &s); err != nil {
+			undo()
+			return nil, nil, err
+		}
+		if change := item.Patch.PriceChange(&before, &s, now); change != nil {
+			r.prices[s.Id] = models.WithPrice(r.prices[s.Id], *change)
+		}
+		r.subscriptions[s.Id] = s
+		updated[i] = &s
+	}
+	if atomic && failed(errs) {
+		undo()
+		return make([]*models.Subscription, len(items)), errs, nil
+	}
+	return updated, errs, nil
+}
+
+func (r *MemorySubscriptionRepository) DeleteMany(ctx context.Context, ids []int64, audit models.AuditInfo, atomic bool) ([]error, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	undo := r.snapshot()
+	errs := make([]error, len(ids))
+	now := time.Now()
+	for i, id := range ids {
+		before, ok := r.subscriptions[id]
+		if !ok || before.DeletedAt != nil {
+			errs[i] = sql.ErrNoRows
+			continue
+		}
+		s := before
+		s.DeletedAt = &now
+		s.Version++
+		if err := r.record(id, models.AuditDelete, audit, &before, &s); err != nil {
+			undo()
+			return nil, err
+		}
+		r.subscriptions[id] = s
+	}
+	if atomic && failed(errs) {
+		undo()
+	}
+	return errs, nil
+}
+
+func (r *MemorySubscriptionRepository) FindDuplicates(ctx context.Context, subs []*models.Subscription) ([]int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	stored := make(map[models.DuplicateKey]int64, len(r.subscriptions))
+	for id, s := range r.subscriptions {
+		k := s.DuplicateKey()
+		if s.DeletedAt == nil && (stored[k] == 0 || id < stored[k]) {
+			stored[k] = id
+		}
+	}
+	ids := make([]int64, len(subs))
+	for i, s := range subs {
+		ids[i] = stored[s.DuplicateKey()]
+	}
+	return ids, nil
+}
+
+// snapshot returns a function restoring the repository to its current state,
+// the in-memory counterpart of rolling back a transaction.
+func (r *MemorySubscriptionRepository) snapshot() (restore func()) {
+	seq, audit := r.seq, len(r.audit)
+	subscriptions := maps.Clone(r.subscriptions)
+	prices := maps.Clone(r.prices)
+	return func() {
+		r.seq, r.audit = seq, r.audit[:audit]
+		r.subscriptions, r.prices = subscriptions, prices
+	}
+}
+
+func (r *MemorySubscriptionRepository) Restore(ctx context.Context, id int64, audit models.AuditInfo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	before, ok := r.subscriptions[id]
+	if !ok || before.DeletedAt == nil {
+		return sql.ErrNoRows
+	}
+	s := before
+	s.DeletedAt = nil
+	s.Version++
+	if err := r.record(id, models.AuditRestore, audit, &before, &s); err != nil {
+		return err
+	}
+	r.subscriptions[id] = s
+	return nil
+}
+
+func (r *MemorySubscriptionRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var purged int64
+	for id, s := range r.subscriptions {
+		if s.DeletedAt == nil || !s.DeletedAt.Before(deletedBefore) {
+			continue
+		}
+		if err := r.record(id, models.AuditPurge, models.AuditInfo{Actor: models.SystemActor}, &s, nil); err != nil {
+			return purged, err
+		}
+		delete(r.subscriptions, id)
+		delete(r.prices, id)
+		purged++
+	}
+	return purged, nil
+}
+
+// record appends a change to the audit log. The caller must hold the write lock.
+func (r *MemorySubscriptionRepository) record(id int64, action string, info models.AuditInfo, before, after *models.Subscription) error {
+	e, err := models.NewAuditEntry(id, action, info, before, after)
+	if err != nil {
+		return err
+	}
+	e.Id = int64(len(r.audit) + 1)
+	r.audit = append(r.audit, e)
+	return nil
+}
+
+func (r *MemorySubscriptionRepository) GetHistory(ctx context.Context, id int64) ([]models.AuditEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entries := []models.AuditEntry{}
+	for _, e := range r.audit {
+		if e.SubscriptionId == id {
+			entries = append(entries, e)
+		}
+	}
+	if _, ok := r.subscriptions[id]; !ok && len(entries) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return entries, nil
+}
+
+func (r *MemorySubscriptionRepository) GetPriceHistory(ctx context.Context, id int64) ([]models.PricePeriod, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if s, ok := r.subscriptions[id]; !ok || s.DeletedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+	return append([]models.PricePeriod{}, r.prices[id]...), nil
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func earlier(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func matchesFilter(s models.Subscription, f *models.SubscriptionFilter) bool {
+	if s.DeletedAt != nil && !f.IncludeDeleted {
+		return false
+	}
+	if f.UserId != "" {
+		id, err := uuid.Parse(f.UserId)
+		if err != nil || s.UserId != id {
+			return false
+		}
+	}
+	if f.ServiceId != 0 && s.ServiceId != f.ServiceId {
+		return false
+	}
+	if f.Currency != "" && s.MonthlyPrice.Currency != f.Currency {
+		return false
+	}
+	if f.MinPrice != nil && s.MonthlyPrice.Amount < *f.MinPrice {
+		return false
+	}
+	if f.MaxPrice != nil && s.MonthlyPrice.Amount > *f.MaxPrice {
+		return false
+	}
+	start := s.StartDate.ToTime()
+	if f.ActiveAt != nil && (start.After(f.ActiveAt.ToTime()) || endsBefore(s, *f.ActiveAt)) {
+		return false
+	}
+	if f.StartFrom != nil && start.Before(f.StartFrom.ToTime()) {
+		return false
+	}
+	if f.StartTo != nil && start.After(f.StartTo.ToTime()) {
+		return false
+	}
+	if f.EndFrom != nil && endsBefore(s, *f.EndFrom) {
+		return false
+	}
+	if f.EndTo != nil && (s.EndDate == nil || s.EndDate.ToTime().After(f.EndTo.ToTime())) {
+		return false
+	}
+	return true
+}
+
+// endsBefore reports whether s ended before m. Open-ended subscriptions never do.
+func endsBefore(s models.Subscription, m models.MonthYear) bool {
+	return s.EndDate != nil && s.EndDate.ToTime().Before(m.ToTime())
+}
+
+// compareSubscriptions orders subscriptions by the given column, breaking ties by id.
+func compareSubscriptions(a, b models.Subscription, sortBy string) int {
+	var c int
+	switch sortBy {
+	case "service_name":
+		c = strings.Compare(a.ServiceName, b.ServiceName)
+	case "monthly_price":
+		c = cmp.Compare(a.MonthlyPrice.Amount, b.MonthlyPrice.Amount)
+	case "start_date":
+		c = a.StartDate.ToTime().Compare(b.StartDate.ToTime())
+	case "end_date":
+		c = compareEndDates(a.EndDate, b.EndDate)
+	}
+	if c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Id, b.Id)
+}
+
+// compareEndDates sorts open-ended subscriptions last, as Postgres does with NULLs.
+func compareEndDates(a, b *models.MonthYear) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return a.ToTime().Compare(b.ToTime())
+}
+
+// renameService renames a service on its subscriptions, versioning and
+// auditing each renamed one like an update.
+func (r *MemorySubscriptionRepository) renameService(serviceId int64, name string, audit models.AuditInfo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, s := range r.subscriptions {
+		if s.ServiceId != serviceId || s.ServiceName == name {
+			continue
+		}
+		before := s
+		s.ServiceName = name
+		s.Version++
+		if err := r.record(id, models.AuditUpdate, audit, &before, &s); err != nil {
+			return err
+		}
+		r.subscriptions[id] = s
+	}
+	return nil
+}
+
+func (r *MemorySubscriptionRepository) usesService(serviceId int64) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, s := range r.subscriptions {
+		if s.ServiceId == serviceId {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+)
+
+// EachSubscription collects the matching subscriptions first and calls fn
+// without holding the lock.
+func (r *MemorySubscriptionRepository) EachSubscription(ctx context.Context, f *models.SubscriptionFilter, fn func(models.Subscription) error) error {
+	r.mu.RLock()
+	var matched []models.Subscription
+	for _, s := range r.subscriptions {
+		if matchesFilter(s, f) {
+			matched = append(matched, s)
+		}
+	}
+	r.mu.RUnlock()
+	sort.Slice(matched, func(i, j int) bool {
+		c := compareSubscriptions(matched[i], matched[j], f.SortBy)
+		if f.Order == "desc" {
+			return c > 0
+		}
+		return c < 0
+	})
+	for _, s := range matched {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MemorySubscriptionRepository) EachInvoiceLine(ctx context.Context, f *models.SubscriptionInvoiceRequest, fn func(models.InvoiceLine) error) error {
+	details, err := r.GetSubscriptionsInvoiceDetails(ctx, f)
+	if err != nil {
+		return err
+	}
+	r.mu.RLock()
+	users := make(map[int64]uuid.UUID, len(details.Subscriptions))
+	for _, item := range details.Subscriptions {
+		users[item.SubscriptionId] = r.subscriptions[item.SubscriptionId].UserId
+	}
+	r.mu.RUnlock()
+	for _, item := range details.Subscriptions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, m := range item.Months {
+			err := fn(models.InvoiceLine{
+				SubscriptionId: item.SubscriptionId,
+				ServiceId:      item.ServiceId,
+				ServiceName:    item.ServiceName,
+				UserId:         users[item.SubscriptionId],
+				Month:          m.Month,
+				Amount:         m.Amount,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
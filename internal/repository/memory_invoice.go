@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+)
+
+// GetSubscriptionsInvoice mirrors the SQL used by the Postgres implementation:
+// each matching subscription is charged for the whole months between the later
+// of its start and from_date and the earlier of its end and to_date, each month
+// at the price in effect then.
+func (r *MemorySubscriptionRepository) GetSubscriptionsInvoice(ctx context.Context, f *models.SubscriptionInvoiceRequest) ([]models.InvoiceAmount, error) {
+	details, err := r.GetSubscriptionsInvoiceDetails(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	type amountKey struct {
+		group    any
+		month    time.Time
+		currency string
+	}
+	index := make(map[amountKey]int)
+	var amounts []models.InvoiceAmount
+	for _, item := range details.Subscriptions {
+		s := r.subscriptions[item.SubscriptionId]
+		for _, m := range item.Months {
+			a := models.InvoiceAmount{Amount: models.Money{Currency: m.Amount.Currency}}
+			k := amountKey{currency: m.Amount.Currency}
+			switch f.GroupBy {
+			case models.GroupByService:
+				k.group, a.Group.ServiceName = s.ServiceName, s.ServiceName
+			case models.GroupByUser:
+				userId := s.UserId
+				k.group, a.Group.UserId = userId, &userId
+			}
+			if f.BilledPerMonth() {
+				month := m.Month
+				k.month, a.Month = month.ToTime(), &month
+				if f.GroupBy == models.GroupByMonth {
+					a.Group.Month = a.Month
+				}
+			}
+			i, ok := index[k]
+			if !ok {
+				i = len(amounts)
+				index[k] = i
+				amounts = append(amounts, a)
+			}
+			amounts[i].Amount.Amount += m.Amount.Amount
+		}
+	}
+	sort.Slice(amounts, func(i, j int) bool {
+		a, b := amounts[i], amounts[j]
+		var c int
+		switch f.GroupBy {
+		case models.GroupByService:
+			c = strings.Compare(a.Group.ServiceName, b.Group.ServiceName)
+		case models.GroupByUser:
+			c = strings.Compare(a.Group.UserId.String(), b.Group.UserId.String())
+		}
+		if c == 0 && a.Month != nil {
+			c = a.Month.ToTime().Compare(b.Month.ToTime())
+		}
+		if c != 0 {
+			return c < 0
+		}
+		return a.Amount.Currency < b.Amount.Currency
+	})
+	return amounts, nil
+}
+
+func (r *MemorySubscriptionRepository) GetSubscriptionsInvoiceDetails(ctx context.Context, f *models.SubscriptionInvoiceRequest) (*models.SubscriptionInvoiceDetails, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	from, to, openEnd := f.Period(time.Now())
+	var matched []models.Subscription
+	for _, s := range r.subscriptions {
+		if s.DeletedAt != nil && !f.IncludeDeleted {
+			continue
+		}
+		if f.ServiceId != 0 && s.ServiceId != f.ServiceId {
+			continue
+		}
+		if f.UserId != uuid.Nil && s.UserId != f.UserId {
+			continue
+		}
+		if s.StartDate.ToTime().After(to) || billingEnd(s, openEnd).Before(from) {
+			continue
+		}
+		matched = append(matched, s)
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Id < matched[j].Id })
+
+	details := &models.SubscriptionInvoiceDetails{Subscriptions: []models.SubscriptionInvoiceItem{}}
+	for _, s := range matched {
+		start, end := later(s.StartDate.ToTime(), from), earlier(billingEnd(s, openEnd), to)
+		for month := start; month.Before(end); month = month.AddDate(0, 1, 0) {
+			s.MonthlyPrice = models.PriceAt(r.prices[s.Id], models.FromTime(month))
+			details.AddMonth(s, models.FromTime(month))
+		}
+	}
+	return details, nil
+}
+
+// billingEnd is the end of s for billing purposes: open-ended subscriptions
+// run until openEnd.
+func billingEnd(s models.Subscription, openEnd time.Time) time.Time {
+	if s.EndDate == nil {
+		return openEnd
+	}
+	return s.EndDate.ToTime()
+}
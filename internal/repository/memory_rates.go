@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+)
+
+type rateKey struct {
+	base, quote string
+	month       time.Time
+}
+
+// MemoryRateRepository keeps currency rates in process memory.
+type MemoryRateRepository struct {
+	mu    sync.RWMutex
+	rates map[rateKey]models.CurrencyRate
+}
+
+func NewMemoryRateRepository() *MemoryRateRepository {
+	return &MemoryRateRepository{rates: make(map[rateKey]models.CurrencyRate)}
+}
+
+func (r *MemoryRateRepository) GetRates(ctx context.Context, f *models.CurrencyRateFilter) ([]models.CurrencyRate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rates := []models.CurrencyRate{}
+	for _, rate := range r.rates {
+		month := rate.Month.ToTime()
+		if (f.Base != "" && rate.Base != f.Base) || (f.Quote != "" && rate.Quote != f.Quote) ||
+			(f.From != nil && month.Before(f.From.ToTime())) || (f.To != nil && month.After(f.To.ToTime())) {
+			continue
+		}
+		rates = append(rates, rate)
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		a, b := rates[i], rates[j]
+		if a.Base != b.Base {
+			return a.Base < b.Base
+		}
+		if a.Quote != b.Quote {
+			return a.Quote < b.Quote
+		}
+		return a.Month.ToTime().Before(b.Month.ToTime())
+	})
+	return rates, nil
+}
+
+func (r *MemoryRateRepository) FindRate(ctx context.Context, base, quote string, month models.MonthYear) (*models.CurrencyRate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var found *models.CurrencyRate
+	for _, rate := range r.rates {
+		if rate.Base != base || rate.Quote != quote || rate.Month.ToTime().After(month.ToTime()) {
+			continue
+		}
+		if found == nil || rate.Month.ToTime().After(found.Month.ToTime()) {
+			found = &rate
+		}
+	}
+	if found == nil {
+		return nil, sql.ErrNoRows
+	}
+	return found, nil
+}
+
+func (r *MemoryRateRepository) SaveRates(ctx context.Context, rates []models.CurrencyRate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rate := range rates {
+		r.rates[rateKey{rate.Base, rate.Quote, rate.Month.ToTime()}] = rate
+	}
+	return nil
+}
+
+func (r *MemoryRateRepository) DeleteRate(ctx context.Context, base, quote string, month models.MonthYear) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := rateKey{base, quote, month.ToTime()}
+	if _, ok := r.rates[key]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.rates, key)
+	return nil
+}
@@ -0,0 +1,21 @@
+package repository
+
+import "context"
+
+// MemorySchemaRepository stands in for the database of the memory
+// repositories, which is always reachable and at the given schema version.
+type MemorySchemaRepository struct {
+	version uint
+}
+
+func NewMemorySchemaRepository(version uint) *MemorySchemaRepository {
+	return &MemorySchemaRepository{version: version}
+}
+
+func (r *MemorySchemaRepository) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (r *MemorySchemaRepository) SchemaVersion(ctx context.Context) (uint, bool, error) {
+	return r.version, false, ctx.Err()
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+)
+
+// MemoryServiceRepository keeps the services catalogue in process memory. It
+// renames services on the subscriptions of the given subscription repository.
+type MemoryServiceRepository struct {
+	mu            sync.RWMutex
+	seq           int64
+	services      map[int64]models.Service
+	subscriptions *MemorySubscriptionRepository
+}
+
+func NewMemoryServiceRepository(subscriptions *MemorySubscriptionRepository) *MemoryServiceRepository {
+	return &MemoryServiceRepository{services: make(map[int64]models.Service), subscriptions: subscriptions}
+}
+
+func (r *MemoryServiceRepository) GetServices(ctx context.Context) ([]models.Service, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	services := []models.Service{}
+	for _, s := range r.services {
+		services = append(services, s)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services, nil
+}
+
+func (r *MemoryServiceRepository) GetServiceById(ctx context.Context, id int64) (*models.Service, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.services[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &s, nil
+}
+
+func (r *MemoryServiceRepository) ResolveService(ctx context.Context, name string) (*models.Service, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key := models.ServiceKey(name)
+	for _, s := range r.services {
+		if slices.Contains(s.Keys(), key) {
+			return &s, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *MemoryServiceRepository) CreateService(ctx context.Context, s *models.Service) error {
+	s.Normalize()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.keysTaken(0, s) {
+		return models.ErrServiceConflict
+	}
+	r.seq++
+	s.Id = r.seq
+	r.services[s.Id] = *s
+	return nil
+}
+
+func (r *MemoryServiceRepository) UpdateService(ctx context.Context, s *models.Service, audit models.AuditInfo) error {
+	s.Normalize()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.services[s.Id]; !ok {
+		return sql.ErrNoRows
+	}
+	if r.keysTaken(s.Id, s) {
+		return models.ErrServiceConflict
+	}
+	if err := r.subscriptions.renameService(s.Id, s.Name, audit); err != nil {
+		return err
+	}
+	r.services[s.Id] = *s
+	return nil
+}
+
+func (r *MemoryServiceRepository) keysTaken(id int64, s *models.Service) bool {
+	for _, other := range r.services {
+		if other.Id == id {
+			continue
+		}
+		for _, key := range s.Keys() {
+			if slices.Contains(other.Keys(), key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *MemoryServiceRepository) DeleteService(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.services[id]; !ok {
+		return sql.ErrNoRows
+	}
+	if r.subscriptions.usesService(id) {
+		return models.ErrServiceInUse
+	}
+	delete(r.services, id)
+	return nil
+}
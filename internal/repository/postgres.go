@@ -0,0 +1,463 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/logger"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+)
+
+// PostgresSubscriptionRepository stores subscriptions in PostgreSQL. Every
+// call runs for at most timeout, unless it is zero.
+type PostgresSubscriptionRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresSubscriptionRepository(db *sql.DB, timeout time.Duration) *PostgresSubscriptionRepository {
+	return &PostgresSubscriptionRepository{db: db, timeout: timeout}
+}
+
+// withTimeout bounds ctx by timeout, the longest a single query or transaction
+// may run. A zero timeout leaves ctx as it is.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+const subscriptionColumns = `id, service_id, service_name, monthly_price, currency, user_id, start_date, end_date, deleted_at, version`
+
+var subscriptionSortColumns = map[string]string{
+	"id":            "id",
+	"service_name":  "service_name",
+	"monthly_price": "monthly_price",
+	"start_date":    "start_date",
+	"end_date":      "COALESCE(end_date, 'infinity'::date)",
+}
+
+// subscriptionSortTypes are the types of subscriptionSortColumns, which the
+// values of a cursor are cast to.
+var subscriptionSortTypes = map[string]string{
+	"id":            "bigint",
+	"service_name":  "text",
+	"monthly_price": "bigint",
+	"start_date":    "date",
+	"end_date":      "date",
+}
+
+// cursorValue is the value of the sort column of after, the subscription a
+// page starts after.
+func cursorValue(after *models.Subscription, sortBy string) any {
+	switch sortBy {
+	case "service_name":
+		return after.ServiceName
+	case "monthly_price":
+		return after.MonthlyPrice.Amount
+	case "start_date":
+		return after.StartDate
+	case "end_date":
+		if after.EndDate == nil {
+			return "infinity"
+		}
+		return *after.EndDate
+	}
+	return after.Id
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSubscription(row rowScanner) (models.Subscription, error) {
+	var s models.Subscription
+	err := row.Scan(&s.Id, &s.ServiceId, &s.ServiceName, &s.MonthlyPrice.Amount, &s.MonthlyPrice.Currency,
+		&s.UserId, &s.StartDate, &s.EndDate, &s.DeletedAt, &s.Version)
+	return s, err
+}
+
+func (r *PostgresSubscriptionRepository) GetById(ctx context.Context, id int64, includeDeleted bool) (*models.Subscription, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+subscriptionColumns+`
+		 FROM subscription WHERE id = $1 AND ($2 OR deleted_at IS NULL)`, id, includeDeleted)
+
+	s, err := scanSubscription(row)
+	if err == sql.ErrNoRows {
+		return nil, sql.ErrNoRows
+	}
+	if err != nil {
+		logger.Log.Error("failed to get subscription by id", slog.Any("err", err))
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *PostgresSubscriptionRepository) GetAll(ctx context.Context, f *models.SubscriptionFilter) (*models.SubscriptionPage, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	conds, args := subscriptionConditions(f)
+	page := &models.SubscriptionPage{Items: []models.Subscription{}}
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM subscription`+whereClause(conds), args...).Scan(&page.TotalCount)
+	if err != nil {
+		logger.Log.Error("failed to count subscriptions", slog.Any("err", err))
+		return nil, err
+	}
+
+	column := subscriptionSortColumns[f.SortBy]
+	cmp, dir := ">", "ASC"
+	if f.Order == "desc" {
+		cmp, dir = "<", "DESC"
+	}
+	if f.After != nil {
+		args = append(args, cursorValue(f.After, f.SortBy), f.After.Id)
+		conds = append(conds, fmt.Sprintf("(%s, id) %s ($%d::%s, $%d)",
+			column, cmp, len(args)-1, subscriptionSortTypes[f.SortBy], len(args)))
+	}
+	args = append(args, f.Limit+1, f.Offset)
+	query := fmt.Sprintf(`SELECT %s FROM subscription%s ORDER BY %s %s, id %s LIMIT $%d OFFSET $%d`,
+		subscriptionColumns, whereClause(conds), column, dir, dir, len(args)-1, len(args))
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Log.Error("failed to get subscriptions", slog.Any("err", err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			logger.Log.Error("failed to scan subscription row", slog.Any("err", err))
+			return nil, err
+		}
+		page.Items = append(page.Items, s)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Log.Error("failed to iterate subscription rows", slog.Any("err", err))
+		return nil, err
+	}
+	if len(page.Items) > f.Limit {
+		page.Items = page.Items[:f.Limit]
+		page.NextCursor = f.NextCursor(page.Items[f.Limit-1])
+	}
+	return page, nil
+}
+
+func (r *PostgresSubscriptionRepository) GetByUserId(ctx context.Context, userId uuid.UUID) ([]models.Subscription, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+subscriptionColumns+` FROM subscription WHERE user_id = $1 AND deleted_at IS NULL ORDER BY start_date, id`, userId)
+	if err != nil {
+		logger.Log.Error("failed to get user subscriptions", slog.Any("user_id", userId), slog.Any("err", err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscriptions []models.Subscription
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			logger.Log.Error("failed to scan subscription row", slog.Any("err", err))
+			return nil, err
+		}
+		subscriptions = append(subscriptions, s)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Log.Error("failed to iterate subscription rows", slog.Any("err", err))
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// subscriptionConditions translates the filter into SQL conditions with
+// numbered placeholders and their arguments.
+func subscriptionConditions(f *models.SubscriptionFilter) ([]string, []any) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if !f.IncludeDeleted {
+		conds = append(conds, "deleted_at IS NULL")
+	}
+	if f.UserId != "" {
+		add("user_id = $%d", f.UserId)
+	}
+	if f.ServiceId != 0 {
+		add("service_id = $%d", f.ServiceId)
+	}
+	if f.Currency != "" {
+		add("currency = $%d", f.Currency)
+	}
+	if f.MinPrice != nil {
+		add("monthly_price >= $%d", *f.MinPrice)
+	}
+	if f.MaxPrice != nil {
+		add("monthly_price <= $%d", *f.MaxPrice)
+	}
+	if f.ActiveAt != nil {
+		add("start_date <= $%d", f.ActiveAt.ToTime())
+		add("(end_date IS NULL OR end_date >= $%d)", f.ActiveAt.ToTime())
+	}
+	if f.StartFrom != nil {
+		add("start_date >= $%d", f.StartFrom.ToTime())
+	}
+	if f.StartTo != nil {
+		add("start_date <= $%d", f.StartTo.ToTime())
+	}
+	if f.EndFrom != nil {
+		add("(end_date IS NULL OR end_date >= $%d)", f.EndFrom.ToTime())
+	}
+	if f.EndTo != nil {
+		add("end_date <= $%d", f.EndTo.ToTime())
+	}
+	return conds, args
+}
+
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+func (r *PostgresSubscriptionRepository) Create(ctx context.Context, s *models.Subscription, audit models.AuditInfo) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Log.Error("failed to begin transaction", slog.Any("err", err))
+		return err
+	}
+	defer tx.Rollback()
+	query := `
+		INSERT INTO subscription (id, service_id, service_name, monthly_price, currency, user_id, start_date, end_date)
+		VALUES (nextval('subscription_seq'), $1, $2, $3, $4, $5, $6, $7) RETURNING id, version`
+	err = tx.QueryRowContext(ctx, query,
+		s.ServiceId, s.ServiceName, s.MonthlyPrice.Amount, s.MonthlyPrice.Currency, s.UserId, s.StartDate, s.EndDate).
+		Scan(&s.Id, &s.Version)
+	if err != nil {
+		logger.Log.Error("failed to create subscription", slog.Any("err", err))
+		return err
+	}
+	err = savePricePeriod(ctx, tx, s.Id, models.PricePeriod{EffectiveFrom: s.StartDate, MonthlyPrice: s.MonthlyPrice})
+	if err != nil {
+		return err
+	}
+	if err := writeAudit(ctx, tx, s.Id, models.AuditCreate, audit, nil, s); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Log.Error("failed to commit subscription", slog.Any("err", err))
+		return err
+	}
+	return nil
+}
+
+func (r *PostgresSubscriptionRepository) Patch(ctx context.Context, id int64, patch *models.SubscriptionPatch, audit models.AuditInfo) (*models.Subscription, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Log.Error("failed to begin transaction", slog.Any("err", err))
+		return nil, err
+	}
+	defer tx.Rollback()
+	before, err := lockSubscription(ctx, tx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := patch.CheckVersion(before.Version); err != nil {
+		return nil, err
+	}
+	s := *before
+	if err := patch.Apply(&s); err != nil {
+		return nil, err
+	}
+	s.Id = before.Id
+	change := patch.PriceChange(before, &s, time.Now())
+	query := `
+	UPDATE subscription 
+	SET service_id = $1, service_name = $2, monthly_price = $3, currency = $4,
+		user_id = $5, start_date = $6, end_date = $7, version = version + 1
+	WHERE id = $8
+	RETURNING version`
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		logger.Log.Error("failed to prepare update statement", slog.Any("err", err))
+		return nil, err
+	}
+	defer stmt.Close()
+	err = stmt.QueryRowContext(ctx, s.ServiceId, s.ServiceName, s.MonthlyPrice.Amount, s.MonthlyPrice.Currency,
+		s.UserId, s.StartDate, s.EndDate, s.Id).Scan(&s.Version)
+	if err != nil {
+		logger.Log.Error("failed to execute update", slog.Any("err", err))
+		return nil, err
+	}
+	if change != nil {
+		if err := savePricePeriod(ctx, tx, s.Id, *change); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeAudit(ctx, tx, s.Id, models.AuditUpdate, audit, before, &s); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Log.Error("failed to commit update", slog.Any("err", err))
+		return nil, err
+	}
+	logger.Log.Info("updated subscription", slog.Any("id", s.Id))
+	return &s, nil
+}
+
+// savePricePeriod puts p in effect from p.EffectiveFrom onwards, replacing the
+// periods of the subscription starting on or after that month.
+func savePricePeriod(ctx context.Context, tx *sql.Tx, id int64, p models.PricePeriod) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM subscription_price_history WHERE subscription_id = $1 AND effective_from >= $2`,
+		id, p.EffectiveFrom)
+	if err != nil {
+		logger.Log.Error("failed to replace price periods", slog.Any("id", id), slog.Any("err", err))
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO subscription_price_history (subscription_id, effective_from, monthly_price, currency)
+		VALUES ($1, $2, $3, $4)`,
+		id, p.EffectiveFrom, p.MonthlyPrice.Amount, p.MonthlyPrice.Currency)
+	if err != nil {
+		logger.Log.Error("failed to save price period", slog.Any("id", id), slog.Any("err", err))
+		return err
+	}
+	return nil
+}
+
+func (r *PostgresSubscriptionRepository) GetPriceHistory(ctx context.Context, id int64) ([]models.PricePeriod, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	if _, err := r.GetById(ctx, id, false); err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT effective_from, monthly_price, currency FROM subscription_price_history
+		WHERE subscription_id = $1 ORDER BY effective_from`, id)
+	if err != nil {
+		logger.Log.Error("failed to get price history", slog.Any("id", id), slog.Any("err", err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	periods := []models.PricePeriod{}
+	for rows.Next() {
+		var p models.PricePeriod
+		if err := rows.Scan(&p.EffectiveFrom, &p.MonthlyPrice.Amount, &p.MonthlyPrice.Currency); err != nil {
+			logger.Log.Error("failed to scan price period", slog.Any("err", err))
+			return nil, err
+		}
+		periods = append(periods, p)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Log.Error("failed to iterate price periods", slog.Any("err", err))
+		return nil, err
+	}
+	return periods, nil
+}
+
+func (r *PostgresSubscriptionRepository) Delete(ctx context.Context, id int64, audit models.AuditInfo) error {
+	return r.setDeleted(ctx, id, true, audit)
+}
+
+func (r *PostgresSubscriptionRepository) Restore(ctx context.Context, id int64, audit models.AuditInfo) error {
+	return r.setDeleted(ctx, id, false, audit)
+}
+
+// setDeleted deletes or restores a subscription. Subscriptions already in the
+// requested state count as missing.
+func (r *PostgresSubscriptionRepository) setDeleted(ctx context.Context, id int64, deleted bool, audit models.AuditInfo) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	action, query := models.AuditDelete,
+		`UPDATE subscription SET deleted_at = now(), version = version + 1 WHERE id = $1 RETURNING deleted_at, version`
+	if !deleted {
+		action, query = models.AuditRestore,
+			`UPDATE subscription SET deleted_at = NULL, version = version + 1 WHERE id = $1 RETURNING deleted_at, version`
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Log.Error("failed to begin transaction", slog.Any("err", err))
+		return err
+	}
+	defer tx.Rollback()
+	before, err := lockSubscription(ctx, tx, id, true)
+	if err != nil {
+		return err
+	}
+	if (before.DeletedAt != nil) == deleted {
+		logger.Log.Warn("no record "+action+"d", slog.Any("id", id))
+		return sql.ErrNoRows
+	}
+	after := *before
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&after.DeletedAt, &after.Version); err != nil {
+		logger.Log.Error("failed to "+action+" subscription", slog.Any("id", id), slog.Any("err", err))
+		return err
+	}
+	if err := writeAudit(ctx, tx, id, action, audit, before, &after); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Log.Error("failed to commit "+action, slog.Any("id", id), slog.Any("err", err))
+		return err
+	}
+	logger.Log.Info(action+"d subscription", slog.Any("id", id))
+	return nil
+}
+
+func (r *PostgresSubscriptionRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Log.Error("failed to begin transaction", slog.Any("err", err))
+		return 0, err
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, `DELETE FROM subscription WHERE deleted_at < $1 RETURNING `+subscriptionColumns, deletedBefore)
+	if err != nil {
+		logger.Log.Error("failed to purge subscriptions", slog.Any("err", err))
+		return 0, err
+	}
+	var purged []models.Subscription
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			rows.Close()
+			logger.Log.Error("failed to scan purged subscription", slog.Any("err", err))
+			return 0, err
+		}
+		purged = append(purged, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		logger.Log.Error("failed to iterate purged subscriptions", slog.Any("err", err))
+		return 0, err
+	}
+	for i := range purged {
+		err := writeAudit(ctx, tx, purged[i].Id, models.AuditPurge, models.AuditInfo{Actor: models.SystemActor}, &purged[i], nil)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Log.Error("failed to commit purge", slog.Any("err", err))
+		return 0, err
+	}
+	return int64(len(purged)), nil
+}
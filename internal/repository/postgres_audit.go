@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/logger"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+)
+
+// lockSubscription reads a subscription and locks its row until the end of tx.
+func lockSubscription(ctx context.Context, tx *sql.Tx, id int64, includeDeleted bool) (*models.Subscription, error) {
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+subscriptionColumns+`
+		 FROM subscription WHERE id = $1 AND ($2 OR deleted_at IS NULL) FOR UPDATE`, id, includeDeleted)
+	s, err := scanSubscription(row)
+	if err == sql.ErrNoRows {
+		return nil, sql.ErrNoRows
+	}
+	if err != nil {
+		logger.Log.Error("failed to lock subscription", slog.Any("id", id), slog.Any("err", err))
+		return nil, err
+	}
+	return &s, nil
+}
+
+// writeAudit records a change of the subscription as part of tx, so that the
+// change and its audit entry are committed together.
+func writeAudit(ctx context.Context, tx *sql.Tx, id int64, action string, info models.AuditInfo, before, after *models.Subscription) error {
+	e, err := models.NewAuditEntry(id, action, info, before, after)
+	if err != nil {
+		logger.Log.Error("failed to snapshot subscription", slog.Any("id", id), slog.Any("err", err))
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO audit_log (subscription_id, action, actor, request_id, before, after, changed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		e.SubscriptionId, e.Action, e.Actor, e.RequestId, nullJSON(e.Before), nullJSON(e.After), e.ChangedAt)
+	if err != nil {
+		logger.Log.Error("failed to write audit log", slog.Any("id", id), slog.Any("err", err))
+		return err
+	}
+	return nil
+}
+
+func nullJSON(b []byte) any {
+	if b == nil {
+		return nil
+	}
+	return string(b)
+}
+
+func (r *PostgresSubscriptionRepository) GetHistory(ctx context.Context, id int64) ([]models.AuditEntry, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, subscription_id, action, actor, request_id, before, after, changed_at
+		FROM audit_log WHERE subscription_id = $1 ORDER BY id`, id)
+	if err != nil {
+		logger.Log.Error("failed to get subscription history", slog.Any("id", id), slog.Any("err", err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var e models.AuditEntry
+		var before, after []byte
+		if err := rows.Scan(&e.Id, &e.SubscriptionId, &e.Action, &e.Actor, &e.RequestId, &before, &after, &e.ChangedAt); err != nil {
+			logger.Log.Error("failed to scan audit entry", slog.Any("err", err))
+			return nil, err
+		}
+		e.Before, e.After = before, after
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Log.Error("failed to iterate audit entries", slog.Any("err", err))
+		return nil, err
+	}
+	if len(entries) > 0 {
+		return entries, nil
+	}
+	var exists bool
+	err = r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM subscription WHERE id = $1)`, id).Scan(&exists)
+	if err != nil {
+		logger.Log.Error("failed to check subscription", slog.Any("id", id), slog.Any("err", err))
+		return nil, err
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+	return entries, nil
+}
@@ -0,0 +1,388 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/logger"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+)
+
+const dateLayout = "2006-01-02"
+
+// CreateMany reserves ids for the subscriptions from the sequence and copies
+// them, their first price periods and their audit entries in with COPY.
+func (r *PostgresSubscriptionRepository) CreateMany(ctx context.Context, subs []*models.Subscription, audit models.AuditInfo) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	if len(subs) == 0 {
+		return nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Log.Error("failed to begin transaction", slog.Any("err", err))
+		return err
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, `SELECT nextval('subscription_seq') FROM generate_series(1, $1)`, len(subs))
+	if err != nil {
+		logger.Log.Error("failed to reserve subscription ids", slog.Any("err", err))
+		return err
+	}
+	for i := 0; rows.Next(); i++ {
+		if err := rows.Scan(&subs[i].Id); err != nil {
+			rows.Close()
+			logger.Log.Error("failed to scan subscription id", slog.Any("err", err))
+			return err
+		}
+		subs[i].Version, subs[i].DeletedAt = 1, nil
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		logger.Log.Error("failed to iterate subscription ids", slog.Any("err", err))
+		return err
+	}
+
+	err = copyIn(ctx, tx, "subscription",
+		[]string{"id", "service_id", "service_name", "monthly_price", "currency", "user_id", "start_date", "end_date"},
+		len(subs), func(i int) []any {
+			s := subs[i]
+			return []any{s.Id, s.ServiceId, s.ServiceName, s.MonthlyPrice.Amount, s.MonthlyPrice.Currency,
+				s.UserId, s.StartDate, s.EndDate}
+		})
+	if err != nil {
+		return err
+	}
+	err = copyIn(ctx, tx, "subscription_price_history",
+		[]string{"subscription_id", "effective_from", "monthly_price", "currency"},
+		len(subs), func(i int) []any {
+			s := subs[i]
+			return []any{s.Id, s.StartDate, s.MonthlyPrice.Amount, s.MonthlyPrice.Currency}
+		})
+	if err != nil {
+		return err
+	}
+	entries := make([]models.AuditEntry, len(subs))
+	for i, s := range subs {
+		e, err := models.NewAuditEntry(s.Id, models.AuditCreate, audit, nil, s)
+		if err != nil {
+			logger.Log.Error("failed to snapshot subscription", slog.Any("id", s.Id), slog.Any("err", err))
+			return err
+		}
+		entries[i] = e
+	}
+	if err := copyAudit(ctx, tx, entries); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Log.Error("failed to commit subscriptions", slog.Any("err", err))
+		return err
+	}
+	logger.Log.Info("created subscriptions", slog.Int("count", len(subs)))
+	return nil
+}
+
+// PatchMany locks every subscription of the batch with one query, applies the
+// patches in memory and writes the resulting rows back with one statement.
+func (r *PostgresSubscriptionRepository) PatchMany(ctx context.Context, items []PatchItem, audit models.AuditInfo, atomic bool) ([]*models.Subscription, []error, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Log.Error("failed to begin transaction", slog.Any("err", err))
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+	ids := make([]int64, len(items))
+	for i, item := range items {
+		ids[i] = item.Id
+	}
+	current, err := lockSubscriptions(ctx, tx, ids, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	updated, errs := make([]*models.Subscription, len(items)), make([]error, len(items))
+	var changed []int64
+	seen := make(map[int64]bool)
+	var prices []PatchItem
+	var periods []models.PricePeriod
+	var entries []models.AuditEntry
+	now := time.Now()
+	for i, item := range items {
+		before, ok := current[item.Id]
+		if !ok {
+			errs[i] = sql.ErrNoRows
+			continue
+		}
+		if err := item.Patch.CheckVersion(before.Version); err != nil {
+			errs[i] = err
+			continue
+		}
+		s := *before
+		if err := item.Patch.Apply(&s); err != nil {
+			errs[i] = err
+			continue
+		}
+		s.Id, s.Version = before.Id, before.Version+1
+		if change := item.Patch.PriceChange(before, &s, now); change != nil {
+			prices = append(prices, item)
+			periods = append(periods, *change)
+		}
+		e, err := models.NewAuditEntry(s.Id, models.AuditUpdate, audit, before, &s)
+		if err != nil {
+			logger.Log.Error("failed to snapshot subscription", slog.Any("id", s.Id), slog.Any("err", err))
+			return nil, nil, err
+		}
+		entries = append(entries, e)
+		if !seen[s.Id] {
+			seen[s.Id] = true
+			changed = append(changed, s.Id)
+		}
+		current[s.Id], updated[i] = &s, &s
+	}
+	if atomic && failed(errs) {
+		return make([]*models.Subscription, len(items)), errs, nil
+	}
+	if len(entries) == 0 {
+		return updated, errs, nil
+	}
+
+	if err := updateSubscriptions(ctx, tx, changed, current); err != nil {
+		return nil, nil, err
+	}
+	for i := range prices {
+		if err := savePricePeriod(ctx, tx, prices[i].Id, periods[i]); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := copyAudit(ctx, tx, entries); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Log.Error("failed to commit updates", slog.Any("err", err))
+		return nil, nil, err
+	}
+	logger.Log.Info("updated subscriptions", slog.Int("count", len(entries)))
+	return updated, errs, nil
+}
+
+// DeleteMany deletes the subscriptions of the batch with one statement.
+// Repeated ids count as missing after their first deletion.
+func (r *PostgresSubscriptionRepository) DeleteMany(ctx context.Context, ids []int64, audit models.AuditInfo, atomic bool) ([]error, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Log.Error("failed to begin transaction", slog.Any("err", err))
+		return nil, err
+	}
+	defer tx.Rollback()
+	current, err := lockSubscriptions(ctx, tx, ids, false)
+	if err != nil {
+		return nil, err
+	}
+	errs := make([]error, len(ids))
+	var deleted []int64
+	seen := make(map[int64]bool)
+	for i, id := range ids {
+		if _, ok := current[id]; !ok || seen[id] {
+			errs[i] = sql.ErrNoRows
+			continue
+		}
+		seen[id] = true
+		deleted = append(deleted, id)
+	}
+	if len(deleted) == 0 || atomic && failed(errs) {
+		return errs, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE subscription SET deleted_at = now(), version = version + 1
+		WHERE id = ANY($1) RETURNING id, deleted_at, version`, pq.Array(deleted))
+	if err != nil {
+		logger.Log.Error("failed to delete subscriptions", slog.Any("err", err))
+		return nil, err
+	}
+	entries := make([]models.AuditEntry, 0, len(deleted))
+	for rows.Next() {
+		var id int64
+		var deletedAt time.Time
+		var version int64
+		if err := rows.Scan(&id, &deletedAt, &version); err != nil {
+			rows.Close()
+			logger.Log.Error("failed to scan deleted subscription", slog.Any("err", err))
+			return nil, err
+		}
+		before := current[id]
+		after := *before
+		after.DeletedAt, after.Version = &deletedAt, version
+		e, err := models.NewAuditEntry(id, models.AuditDelete, audit, before, &after)
+		if err != nil {
+			rows.Close()
+			logger.Log.Error("failed to snapshot subscription", slog.Any("id", id), slog.Any("err", err))
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		logger.Log.Error("failed to iterate deleted subscriptions", slog.Any("err", err))
+		return nil, err
+	}
+	if err := copyAudit(ctx, tx, entries); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Log.Error("failed to commit deletes", slog.Any("err", err))
+		return nil, err
+	}
+	logger.Log.Info("deleted subscriptions", slog.Int("count", len(deleted)))
+	return errs, nil
+}
+
+// FindDuplicates matches all of subs against the stored subscriptions with
+// one query.
+func (r *PostgresSubscriptionRepository) FindDuplicates(ctx context.Context, subs []*models.Subscription) ([]int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	n := len(subs)
+	ids := make([]int64, n)
+	if n == 0 {
+		return ids, nil
+	}
+	users, serviceIds, prices, currencies := make([]string, n), make([]int64, n), make([]int64, n), make([]string, n)
+	starts, ends := make([]string, n), make([]sql.NullString, n)
+	for i, s := range subs {
+		users[i], serviceIds[i] = s.UserId.String(), s.ServiceId
+		prices[i], currencies[i] = s.MonthlyPrice.Amount, s.MonthlyPrice.Currency
+		starts[i] = s.StartDate.ToTime().Format(dateLayout)
+		if s.EndDate != nil {
+			ends[i] = sql.NullString{String: s.EndDate.ToTime().Format(dateLayout), Valid: true}
+		}
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT v.i, MIN(s.id)
+		FROM unnest($1::uuid[], $2::bigint[], $3::bigint[], $4::text[], $5::date[], $6::date[]) WITH ORDINALITY
+			AS v(user_id, service_id, monthly_price, currency, start_date, end_date, i)
+		JOIN subscription s ON s.user_id = v.user_id AND s.service_id = v.service_id
+			AND s.monthly_price = v.monthly_price AND s.currency = v.currency
+			AND s.start_date = v.start_date AND s.end_date IS NOT DISTINCT FROM v.end_date
+		WHERE s.deleted_at IS NULL
+		GROUP BY v.i`,
+		pq.Array(users), pq.Array(serviceIds), pq.Array(prices), pq.Array(currencies),
+		pq.Array(starts), pq.Array(ends))
+	if err != nil {
+		logger.Log.Error("failed to find duplicate subscriptions", slog.Any("err", err))
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var i, id int64
+		if err := rows.Scan(&i, &id); err != nil {
+			logger.Log.Error("failed to scan duplicate subscription", slog.Any("err", err))
+			return nil, err
+		}
+		ids[i-1] = id
+	}
+	if err := rows.Err(); err != nil {
+		logger.Log.Error("failed to iterate duplicate subscriptions", slog.Any("err", err))
+		return nil, err
+	}
+	return ids, nil
+}
+
+// lockSubscriptions reads the subscriptions with the given ids and locks their
+// rows until the end of tx. Missing ids are left out of the result.
+func lockSubscriptions(ctx context.Context, tx *sql.Tx, ids []int64, includeDeleted bool) (map[int64]*models.Subscription, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+subscriptionColumns+`
+		 FROM subscription WHERE id = ANY($1) AND ($2 OR deleted_at IS NULL) ORDER BY id FOR UPDATE`,
+		pq.Array(ids), includeDeleted)
+	if err != nil {
+		logger.Log.Error("failed to lock subscriptions", slog.Any("err", err))
+		return nil, err
+	}
+	defer rows.Close()
+	found := make(map[int64]*models.Subscription, len(ids))
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			logger.Log.Error("failed to scan subscription row", slog.Any("err", err))
+			return nil, err
+		}
+		found[s.Id] = &s
+	}
+	if err := rows.Err(); err != nil {
+		logger.Log.Error("failed to iterate subscription rows", slog.Any("err", err))
+		return nil, err
+	}
+	return found, nil
+}
+
+// updateSubscriptions writes the state of the given subscriptions, their
+// versions included, with a single statement.
+func updateSubscriptions(ctx context.Context, tx *sql.Tx, ids []int64, subs map[int64]*models.Subscription) error {
+	n := len(ids)
+	serviceIds, names, prices, currencies := make([]int64, n), make([]string, n), make([]int64, n), make([]string, n)
+	users, starts, ends, versions := make([]string, n), make([]string, n), make([]sql.NullString, n), make([]int64, n)
+	for i, id := range ids {
+		s := subs[id]
+		serviceIds[i], names[i] = s.ServiceId, s.ServiceName
+		prices[i], currencies[i] = s.MonthlyPrice.Amount, s.MonthlyPrice.Currency
+		users[i], starts[i], versions[i] = s.UserId.String(), s.StartDate.ToTime().Format(dateLayout), s.Version
+		if s.EndDate != nil {
+			ends[i] = sql.NullString{String: s.EndDate.ToTime().Format(dateLayout), Valid: true}
+		}
+	}
+	_, err := tx.ExecContext(ctx, `
+		UPDATE subscription AS s
+		SET service_id = v.service_id, service_name = v.service_name, monthly_price = v.monthly_price,
+			currency = v.currency, user_id = v.user_id, start_date = v.start_date, end_date = v.end_date,
+			version = v.version
+		FROM unnest($1::bigint[], $2::bigint[], $3::text[], $4::bigint[], $5::text[], $6::uuid[],
+			$7::date[], $8::date[], $9::bigint[])
+			AS v(id, service_id, service_name, monthly_price, currency, user_id, start_date, end_date, version)
+		WHERE s.id = v.id`,
+		pq.Array(ids), pq.Array(serviceIds), pq.Array(names), pq.Array(prices), pq.Array(currencies),
+		pq.Array(users), pq.Array(starts), pq.Array(ends), pq.Array(versions))
+	if err != nil {
+		logger.Log.Error("failed to update subscriptions", slog.Any("err", err))
+		return err
+	}
+	return nil
+}
+
+// copyAudit records the entries in the audit log with COPY.
+func copyAudit(ctx context.Context, tx *sql.Tx, entries []models.AuditEntry) error {
+	return copyIn(ctx, tx, "audit_log",
+		[]string{"subscription_id", "action", "actor", "request_id", "before", "after", "changed_at"},
+		len(entries), func(i int) []any {
+			e := entries[i]
+			return []any{e.SubscriptionId, e.Action, e.Actor, e.RequestId, nullJSON(e.Before), nullJSON(e.After), e.ChangedAt}
+		})
+}
+
+// copyIn streams n rows into table with COPY as part of tx.
+func copyIn(ctx context.Context, tx *sql.Tx, table string, columns []string, n int, row func(i int) []any) error {
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(table, columns...))
+	if err != nil {
+		logger.Log.Error("failed to start copy", slog.String("table", table), slog.Any("err", err))
+		return err
+	}
+	defer stmt.Close()
+	for i := 0; i < n; i++ {
+		if _, err := stmt.ExecContext(ctx, row(i)...); err != nil {
+			logger.Log.Error("failed to copy row", slog.String("table", table), slog.Any("err", err))
+			return err
+		}
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		logger.Log.Error("failed to finish copy", slog.String("table", table), slog.Any("err", err))
+		return err
+	}
+	return nil
+}
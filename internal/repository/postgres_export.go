@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/logger"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+)
+
+// cursorBatchSize is the number of rows fetched from a cursor at a time.
+const cursorBatchSize = 1000
+
+func (r *PostgresSubscriptionRepository) EachSubscription(ctx context.Context, f *models.SubscriptionFilter, fn func(models.Subscription) error) error {
+	conds, args := subscriptionConditions(f)
+	dir := "ASC"
+	if f.Order == "desc" {
+		dir = "DESC"
+	}
+	query := fmt.Sprintf(`SELECT %s FROM subscription%s ORDER BY %s %s, id %s`,
+		subscriptionColumns, whereClause(conds), subscriptionSortColumns[f.SortBy], dir, dir)
+	return eachRow(ctx, r, query, args, func(row rowScanner) (models.Subscription, error) {
+		s, err := scanSubscription(row)
+		if err != nil {
+			logger.Log.Error("failed to scan subscription row", slog.Any("err", err))
+		}
+		return s, err
+	}, fn)
+}
+
+func (r *PostgresSubscriptionRepository) EachInvoiceLine(ctx context.Context, f *models.SubscriptionInvoiceRequest, fn func(models.InvoiceLine) error) error {
+	conds, args := invoiceConditions(f)
+	query := `SELECT id, service_id, service_name, user_id, monthly_price, currency, month
+	FROM ` + pricedSubscriptions + `, ` + billedMonths + whereClause(conds) + `
+	ORDER BY id, month`
+	return eachRow(ctx, r, query, args, func(row rowScanner) (models.InvoiceLine, error) {
+		var l models.InvoiceLine
+		err := row.Scan(&l.SubscriptionId, &l.ServiceId, &l.ServiceName, &l.UserId,
+			&l.Amount.Amount, &l.Amount.Currency, &l.Month)
+		if err != nil {
+			logger.Log.Error("failed to scan invoice row", slog.Any("err", err))
+		}
+		return l, err
+	}, fn)
+}
+
+// eachRow runs query through a cursor in a read-only transaction and calls fn
+// with every row, scanned by scan, so that a result of any size is never held
+// in memory. Rows are fetched cursorBatchSize at a time, each fetch bounded by
+// the query timeout, and passed to fn only once their fetch is done: the
+// export as a whole runs as long as ctx allows, however slowly fn consumes it.
+// An error from fn stops the iteration.
+func eachRow[T any](ctx context.Context, r *PostgresSubscriptionRepository, query string, args []any,
+	scan func(row rowScanner) (T, error), fn func(T) error) error {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		logger.Log.Error("failed to begin transaction", slog.Any("err", err))
+		return err
+	}
+	defer tx.Rollback()
+	declareCtx, cancel := withTimeout(ctx, r.timeout)
+	_, err = tx.ExecContext(declareCtx, `DECLARE export NO SCROLL CURSOR FOR `+query, args...)
+	cancel()
+	if err != nil {
+		logger.Log.Error("failed to declare cursor", slog.Any("err", err))
+		return err
+	}
+	fetch := fmt.Sprintf(`FETCH %d FROM export`, cursorBatchSize)
+	batch := make([]T, 0, cursorBatchSize)
+	for {
+		batch, err = fetchBatch(ctx, r, tx, fetch, scan, batch[:0])
+		if err != nil {
+			return err
+		}
+		for _, v := range batch {
+			if err := fn(v); err != nil {
+				return err
+			}
+		}
+		if len(batch) < cursorBatchSize {
+			return nil
+		}
+	}
+}
+
+// fetchBatch runs one fetch of eachRow and appends its rows to batch.
+func fetchBatch[T any](ctx context.Context, r *PostgresSubscriptionRepository, tx *sql.Tx, fetch string,
+	scan func(row rowScanner) (T, error), batch []T) ([]T, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	rows, err := tx.QueryContext(ctx, fetch)
+	if err != nil {
+		logger.Log.Error("failed to fetch from cursor", slog.Any("err", err))
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		v, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		batch = append(batch, v)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Log.Error("failed to iterate cursor rows", slog.Any("err", err))
+		return nil, err
+	}
+	return batch, nil
+}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/logger"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+)
+
+// pricedSubscriptions splits each subscription into one row per price period,
+// with start_date and end_date narrowed to the months charged at that price.
+// The first period also covers any months before it takes effect, which a later
+// start_date change can leave uncovered. Periods after the end are dropped.
+const pricedSubscriptions = `(
+	SELECT * FROM (
+		SELECT s.id, s.service_id, s.service_name, s.user_id, s.deleted_at, p.monthly_price, p.currency,
+			CASE WHEN LAG(p.effective_from) OVER w IS NULL THEN s.start_date
+				ELSE GREATEST(p.effective_from, s.start_date) END AS start_date,
+			CASE WHEN LEAD(p.effective_from) OVER w IS NULL THEN s.end_date
+				ELSE LEAST(COALESCE(s.end_date, 'infinity'::date), LEAD(p.effective_from) OVER w) END AS end_date
+		FROM subscription s
+		JOIN subscription_price_history p ON p.subscription_id = s.id
+		WINDOW w AS (PARTITION BY s.id ORDER BY p.effective_from)
+	) periods
+	WHERE end_date IS NULL OR start_date < end_date
+) subscription`
+
+// billedEnd is the end of a subscription for billing purposes: open-ended
+// subscriptions run until $3, the start of the month after the current one.
+const billedEnd = `COALESCE(end_date, $3)`
+
+// monthsBilled is the number of whole months a subscription is charged for
+// between $1 (from_date) and $2 (to_date).
+const monthsBilled = `
+	(DATE_PART('year', age(
+		LEAST(` + billedEnd + `, $2),
+		GREATEST(start_date, $1)
+	)) * 12
+	+ DATE_PART('month', age(
+		LEAST(` + billedEnd + `, $2),
+		GREATEST(start_date, $1)
+	)))::int`
+
+// billedMonths expands each subscription into one row per month it is charged
+// for between $1 (from_date) and $2 (to_date).
+const billedMonths = `
+	generate_series(
+		GREATEST(start_date, $1)::timestamp,
+		LEAST(` + billedEnd + `, $2)::timestamp - INTERVAL '1 month',
+		INTERVAL '1 month'
+	) AS month`
+
+var invoiceGroupColumns = map[string]string{
+	models.GroupByService: "service_name",
+	models.GroupByUser:    "user_id",
+	models.GroupByMonth:   "month",
+}
+
+func (r *PostgresSubscriptionRepository) GetSubscriptionsInvoice(ctx context.Context, f *models.SubscriptionInvoiceRequest) ([]models.InvoiceAmount, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	conds, args := invoiceConditions(f)
+	var keys []string
+	if column, ok := invoiceGroupColumns[f.GroupBy]; ok {
+		keys = append(keys, column)
+	}
+	from, cost := pricedSubscriptions, monthsBilled+" * monthly_price"
+	if f.BilledPerMonth() {
+		from, cost = pricedSubscriptions+", "+billedMonths, "monthly_price"
+		if f.GroupBy != models.GroupByMonth {
+			keys = append(keys, "month")
+		}
+	}
+	keys = append(keys, "currency")
+	groupBy := strings.Join(keys, ", ")
+	query := fmt.Sprintf(`SELECT %s, SUM(%s) FROM %s%s GROUP BY %s ORDER BY %s`,
+		groupBy, cost, from, whereClause(conds), groupBy, groupBy)
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Log.Error("failed to fetch subscriptions invoice", slog.Any("err", err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var amounts []models.InvoiceAmount
+	for rows.Next() {
+		var a models.InvoiceAmount
+		var dest []any
+		switch f.GroupBy {
+		case models.GroupByService:
+			dest = append(dest, &a.Group.ServiceName)
+		case models.GroupByUser:
+			a.Group.UserId = new(uuid.UUID)
+			dest = append(dest, a.Group.UserId)
+		}
+		if f.BilledPerMonth() {
+			a.Month = new(models.MonthYear)
+			dest = append(dest, a.Month)
+			if f.GroupBy == models.GroupByMonth {
+				a.Group.Month = a.Month
+			}
+		}
+		dest = append(dest, &a.Amount.Currency, &a.Amount.Amount)
+		if err := rows.Scan(dest...); err != nil {
+			logger.Log.Error("failed to scan invoice row", slog.Any("err", err))
+			return nil, err
+		}
+		amounts = append(amounts, a)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Log.Error("failed to iterate invoice rows", slog.Any("err", err))
+		return nil, err
+	}
+	return amounts, nil
+}
+
+func (r *PostgresSubscriptionRepository) GetSubscriptionsInvoiceDetails(ctx context.Context, f *models.SubscriptionInvoiceRequest) (*models.SubscriptionInvoiceDetails, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	conds, args := invoiceConditions(f)
+	query := `SELECT id, service_id, service_name, monthly_price, currency, month
+	FROM ` + pricedSubscriptions + `, ` + billedMonths + whereClause(conds) + `
+	ORDER BY id, month`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logger.Log.Error("failed to fetch subscriptions invoice details", slog.Any("err", err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	details := &models.SubscriptionInvoiceDetails{Subscriptions: []models.SubscriptionInvoiceItem{}}
+	for rows.Next() {
+		var s models.Subscription
+		var month models.MonthYear
+		if err := rows.Scan(&s.Id, &s.ServiceId, &s.ServiceName, &s.MonthlyPrice.Amount, &s.MonthlyPrice.Currency, &month); err != nil {
+			logger.Log.Error("failed to scan invoice row", slog.Any("err", err))
+			return nil, err
+		}
+		details.AddMonth(s, month)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Log.Error("failed to iterate invoice rows", slog.Any("err", err))
+		return nil, err
+	}
+	return details, nil
+}
+
+// invoiceConditions selects the subscriptions overlapping the invoice period.
+// $1, $2 and $3 are always from_date, to_date and the open-ended billing end.
+func invoiceConditions(f *models.SubscriptionInvoiceRequest) ([]string, []any) {
+	from, to, openEnd := f.Period(time.Now())
+	conds := []string{"start_date <= $2", billedEnd + " >= $1"}
+	args := []any{from, to, openEnd}
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if !f.IncludeDeleted {
+		conds = append(conds, "deleted_at IS NULL")
+	}
+	if f.ServiceId != 0 {
+		add("service_id = $%d", f.ServiceId)
+	}
+	if f.UserId != uuid.Nil {
+		add("user_id = $%d", f.UserId)
+	}
+	return conds, args
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/logger"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+)
+
+// PostgresRateRepository stores currency rates in PostgreSQL. Calls are
+// bounded by timeout like those of PostgresSubscriptionRepository.
+type PostgresRateRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresRateRepository(db *sql.DB, timeout time.Duration) *PostgresRateRepository {
+	return &PostgresRateRepository{db: db, timeout: timeout}
+}
+
+const rateColumns = `base_currency, quote_currency, month, rate`
+
+func scanRate(row rowScanner) (models.CurrencyRate, error) {
+	var rate models.CurrencyRate
+	err := row.Scan(&rate.Base, &rate.Quote, &rate.Month, &rate.Rate)
+	return rate, err
+}
+
+func (r *PostgresRateRepository) GetRates(ctx context.Context, f *models.CurrencyRateFilter) ([]models.CurrencyRate, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Base != "" {
+		add("base_currency = $%d", f.Base)
+	}
+	if f.Quote != "" {
+		add("quote_currency = $%d", f.Quote)
+	}
+	if f.From != nil {
+		add("month >= $%d", f.From)
+	}
+	if f.To != nil {
+		add("month <= $%d", f.To)
+	}
+	rows, err := r.db.QueryContext(ctx, `SELECT `+rateColumns+` FROM currency_rate`+whereClause(conds)+
+		` ORDER BY base_currency, quote_currency, month`, args...)
+	if err != nil {
+		logger.Log.Error("failed to get currency rates", slog.Any("err", err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []models.CurrencyRate{}
+	for rows.Next() {
+		rate, err := scanRate(rows)
+		if err != nil {
+			logger.Log.Error("failed to scan currency rate row", slog.Any("err", err))
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Log.Error("failed to iterate currency rate rows", slog.Any("err", err))
+		return nil, err
+	}
+	return rates, nil
+}
+
+func (r *PostgresRateRepository) FindRate(ctx context.Context, base, quote string, month models.MonthYear) (*models.CurrencyRate, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	row := r.db.QueryRowContext(ctx, `SELECT `+rateColumns+` FROM currency_rate
+		WHERE base_currency = $1 AND quote_currency = $2 AND month <= $3
+		ORDER BY month DESC LIMIT 1`, base, quote, month)
+	rate, err := scanRate(row)
+	if err == sql.ErrNoRows {
+		return nil, sql.ErrNoRows
+	}
+	if err != nil {
+		logger.Log.Error("failed to find currency rate", slog.Any("err", err))
+		return nil, err
+	}
+	return &rate, nil
+}
+
+func (r *PostgresRateRepository) SaveRates(ctx context.Context, rates []models.CurrencyRate) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Log.Error("failed to begin transaction", slog.Any("err", err))
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, `
+	INSERT INTO currency_rate (`+rateColumns+`) VALUES ($1, $2, $3, $4)
+	ON CONFLICT (base_currency, quote_currency, month) DO UPDATE SET rate = EXCLUDED.rate`)
+	if err != nil {
+		logger.Log.Error("failed to prepare currency rate upsert", slog.Any("err", err))
+		return err
+	}
+	defer stmt.Close()
+	for _, rate := range rates {
+		if _, err := stmt.ExecContext(ctx, rate.Base, rate.Quote, rate.Month, rate.Rate); err != nil {
+			logger.Log.Error("failed to save currency rate", slog.Any("err", err))
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Log.Error("failed to commit currency rates", slog.Any("err", err))
+		return err
+	}
+	logger.Log.Info("saved currency rates", slog.Int("count", len(rates)))
+	return nil
+}
+
+func (r *PostgresRateRepository) DeleteRate(ctx context.Context, base, quote string, month models.MonthYear) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM currency_rate WHERE base_currency = $1 AND quote_currency = $2 AND month = $3`,
+		base, quote, month)
+	if err != nil {
+		logger.Log.Error("failed to delete currency rate", slog.Any("err", err))
+		return err
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		logger.Log.Error("failed to get rows affected for delete", slog.Any("err", err))
+		return err
+	}
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	logger.Log.Info("deleted currency rate", slog.String("base", base), slog.String("quote", quote))
+	return nil
+}
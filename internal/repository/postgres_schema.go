@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/lib/pq"
+)
+
+// undefinedTable is the SQLSTATE of a query on a table that does not exist.
+const undefinedTable = "42P01"
+
+// PostgresSchemaRepository reads the schema version golang-migrate records in
+// its migrations table.
+type PostgresSchemaRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresSchemaRepository(db *sql.DB, timeout time.Duration) *PostgresSchemaRepository {
+	return &PostgresSchemaRepository{db: db, timeout: timeout}
+}
+
+func (r *PostgresSchemaRepository) Ping(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	return r.db.PingContext(ctx)
+}
+
+// SchemaVersion returns 0 while the migrations table has not been created.
+func (r *PostgresSchemaRepository) SchemaVersion(ctx context.Context) (uint, bool, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	var version int64
+	var dirty bool
+	err := r.db.QueryRowContext(ctx,
+		`SELECT version, dirty FROM `+postgres.DefaultMigrationsTable+` LIMIT 1`).Scan(&version, &dirty)
+	var pqErr *pq.Error
+	if err == sql.ErrNoRows || errors.As(err, &pqErr) && pqErr.Code == undefinedTable {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return uint(version), dirty, nil
+}
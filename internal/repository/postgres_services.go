@@ -0,0 +1,301 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/logger"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+)
+
+// PostgresServiceRepository stores the services catalogue in PostgreSQL. Calls
+// are bounded by timeout like those of PostgresSubscriptionRepository.
+type PostgresServiceRepository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewPostgresServiceRepository(db *sql.DB, timeout time.Duration) *PostgresServiceRepository {
+	return &PostgresServiceRepository{db: db, timeout: timeout}
+}
+
+const serviceColumns = `id, name, aliases, default_price, default_currency, category`
+
+// uniqueViolation is the SQLSTATE of a write that breaks a unique constraint.
+const uniqueViolation = "23505"
+
+func scanService(row rowScanner) (models.Service, error) {
+	var s models.Service
+	var price sql.NullInt64
+	var currency sql.NullString
+	err := row.Scan(&s.Id, &s.Name, pq.Array(&s.Aliases), &price, &currency, &s.Category)
+	if err == nil && price.Valid {
+		s.DefaultPrice = &models.Money{Amount: price.Int64, Currency: currency.String}
+	}
+	if s.Aliases == nil {
+		s.Aliases = []string{}
+	}
+	return s, err
+}
+
+// defaultPriceArgs returns the default price as nullable amount and currency.
+func defaultPriceArgs(s *models.Service) (any, any) {
+	if s.DefaultPrice == nil {
+		return nil, nil
+	}
+	return s.DefaultPrice.Amount, s.DefaultPrice.Currency
+}
+
+func (r *PostgresServiceRepository) GetServices(ctx context.Context) ([]models.Service, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	rows, err := r.db.QueryContext(ctx, `SELECT `+serviceColumns+` FROM service ORDER BY name`)
+	if err != nil {
+		logger.Log.Error("failed to get services", slog.Any("err", err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	services := []models.Service{}
+	for rows.Next() {
+		s, err := scanService(rows)
+		if err != nil {
+			logger.Log.Error("failed to scan service row", slog.Any("err", err))
+			return nil, err
+		}
+		services = append(services, s)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Log.Error("failed to iterate service rows", slog.Any("err", err))
+		return nil, err
+	}
+	return services, nil
+}
+
+func (r *PostgresServiceRepository) GetServiceById(ctx context.Context, id int64) (*models.Service, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	s, err := scanService(r.db.QueryRowContext(ctx, `SELECT `+serviceColumns+` FROM service WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, sql.ErrNoRows
+	}
+	if err != nil {
+		logger.Log.Error("failed to get service by id", slog.Any("err", err))
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *PostgresServiceRepository) ResolveService(ctx context.Context, name string) (*models.Service, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	s, err := scanService(r.db.QueryRowContext(ctx, `SELECT `+serviceColumns+` FROM service
+		WHERE id = (SELECT service_id FROM service_key WHERE key = $1)`, models.ServiceKey(name)))
+	if err == sql.ErrNoRows {
+		return nil, sql.ErrNoRows
+	}
+	if err != nil {
+		logger.Log.Error("failed to resolve service", slog.String("name", name), slog.Any("err", err))
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *PostgresServiceRepository) CreateService(ctx context.Context, s *models.Service) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	s.Normalize()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Log.Error("failed to begin transaction", slog.Any("err", err))
+		return err
+	}
+	defer tx.Rollback()
+	price, currency := defaultPriceArgs(s)
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO service (`+serviceColumns+`)
+		VALUES (nextval('service_seq'), $1, $2, $3, $4, $5) RETURNING id`,
+		s.Name, pq.Array(s.Aliases), price, currency, s.Category).Scan(&s.Id)
+	if err = serviceConflict(err); err != nil {
+		if err != models.ErrServiceConflict {
+			logger.Log.Error("failed to create service", slog.Any("err", err))
+		}
+		return err
+	}
+	if err := saveServiceKeys(ctx, tx, s); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Log.Error("failed to commit service", slog.Any("err", err))
+		return err
+	}
+	logger.Log.Info("created service", slog.Any("id", s.Id))
+	return nil
+}
+
+func (r *PostgresServiceRepository) UpdateService(ctx context.Context, s *models.Service, audit models.AuditInfo) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	s.Normalize()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Log.Error("failed to begin transaction", slog.Any("err", err))
+		return err
+	}
+	defer tx.Rollback()
+	price, currency := defaultPriceArgs(s)
+	res, err := tx.ExecContext(ctx, `
+		UPDATE service SET name = $1, aliases = $2, default_price = $3, default_currency = $4, category = $5
+		WHERE id = $6`,
+		s.Name, pq.Array(s.Aliases), price, currency, s.Category, s.Id)
+	if err = serviceConflict(err); err != nil {
+		if err != models.ErrServiceConflict {
+			logger.Log.Error("failed to update service", slog.Any("err", err))
+		}
+		return err
+	}
+	if updated, err := res.RowsAffected(); err != nil {
+		logger.Log.Error("failed to get rows affected for update", slog.Any("err", err))
+		return err
+	} else if updated == 0 {
+		return sql.ErrNoRows
+	}
+	if err := saveServiceKeys(ctx, tx, s); err != nil {
+		return err
+	}
+	if err := renameService(ctx, tx, s.Id, s.Name, audit); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Log.Error("failed to commit service", slog.Any("err", err))
+		return err
+	}
+	logger.Log.Info("updated service", slog.Any("id", s.Id))
+	return nil
+}
+
+// renameService renames a service on its subscriptions as part of tx. Each
+// renamed subscription gets a new version and an audit entry, like any update.
+func renameService(ctx context.Context, tx *sql.Tx, id int64, name string, audit models.AuditInfo) error {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+subscriptionColumns+`
+		 FROM subscription WHERE service_id = $1 AND service_name <> $2 ORDER BY id FOR UPDATE`, id, name)
+	if err != nil {
+		logger.Log.Error("failed to lock subscriptions of service", slog.Any("id", id), slog.Any("err", err))
+		return err
+	}
+	var renamed []models.Subscription
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			rows.Close()
+			logger.Log.Error("failed to scan subscription row", slog.Any("err", err))
+			return err
+		}
+		renamed = append(renamed, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		logger.Log.Error("failed to iterate subscription rows", slog.Any("err", err))
+		return err
+	}
+	if len(renamed) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(renamed))
+	entries := make([]models.AuditEntry, len(renamed))
+	for i := range renamed {
+		before := renamed[i]
+		after := before
+		after.ServiceName, after.Version = name, before.Version+1
+		e, err := models.NewAuditEntry(before.Id, models.AuditUpdate, audit, &before, &after)
+		if err != nil {
+			logger.Log.Error("failed to snapshot subscription", slog.Any("id", before.Id), slog.Any("err", err))
+			return err
+		}
+		ids[i], entries[i] = before.Id, e
+	}
+	_, err = tx.ExecContext(ctx,
+		`UPDATE subscription SET service_name = $1, version = version + 1 WHERE id = ANY($2)`, name, pq.Array(ids))
+	if err != nil {
+		logger.Log.Error("failed to rename service on subscriptions", slog.Any("id", id), slog.Any("err", err))
+		return err
+	}
+	return copyAudit(ctx, tx, entries)
+}
+
+// saveServiceKeys makes the name and aliases of s the keys it is resolved by,
+// as part of tx. The primary key of service_key fails the write with
+// models.ErrServiceConflict when another service holds one of them already,
+// or claims it in a transaction running at the same time.
+func saveServiceKeys(ctx context.Context, tx *sql.Tx, s *models.Service) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM service_key WHERE service_id = $1`, s.Id); err != nil {
+		logger.Log.Error("failed to clear service keys", slog.Any("id", s.Id), slog.Any("err", err))
+		return err
+	}
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO service_key (key, service_id) SELECT unnest($1::text[]), $2`, pq.Array(s.Keys()), s.Id)
+	if err = serviceConflict(err); err != nil {
+		if err != models.ErrServiceConflict {
+			logger.Log.Error("failed to save service keys", slog.Any("id", s.Id), slog.Any("err", err))
+		}
+		return err
+	}
+	return nil
+}
+
+// serviceConflict returns models.ErrServiceConflict for err breaking the
+// uniqueness of service names or keys, and err otherwise.
+func serviceConflict(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return models.ErrServiceConflict
+	}
+	return err
+}
+
+func (r *PostgresServiceRepository) DeleteService(ctx context.Context, id int64) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Log.Error("failed to begin transaction", slog.Any("err", err))
+		return err
+	}
+	defer tx.Rollback()
+	// the lock holds back subscriptions being created for the service, as their
+	// foreign key check waits for it, until the service is gone
+	var found int64
+	err = tx.QueryRowContext(ctx, `SELECT id FROM service WHERE id = $1 FOR UPDATE`, id).Scan(&found)
+	if err == sql.ErrNoRows {
+		return sql.ErrNoRows
+	}
+	if err != nil {
+		logger.Log.Error("failed to lock service", slog.Any("id", id), slog.Any("err", err))
+		return err
+	}
+	var used bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM subscription WHERE service_id = $1)`, id).Scan(&used)
+	if err != nil {
+		logger.Log.Error("failed to check service usage", slog.Any("id", id), slog.Any("err", err))
+		return err
+	}
+	if used {
+		return models.ErrServiceInUse
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM service WHERE id = $1`, id); err != nil {
+		logger.Log.Error("failed to delete service", slog.Any("id", id), slog.Any("err", err))
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Log.Error("failed to commit service deletion", slog.Any("id", id), slog.Any("err", err))
+		return err
+	}
+	logger.Log.Info("deleted service", slog.Any("id", id))
+	return nil
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+)
+
+// SubscriptionRepository is the storage backend used by the routes layer.
+// Every call is bounded by its ctx, which the Postgres implementations also
+// limit to their query timeout. Lookups of a missing subscription return sql.ErrNoRows. Deleted subscriptions
+// are kept until purged and count as missing unless asked for explicitly.
+// Every mutation is recorded in the audit log together with the change.
+type SubscriptionRepository interface {
+	GetById(ctx context.Context, id int64, includeDeleted bool) (*models.Subscription, error)
+	GetAll(ctx context.Context, f *models.SubscriptionFilter) (*models.SubscriptionPage, error)
+	GetByUserId(ctx context.Context, userId uuid.UUID) ([]models.Subscription, error)
+	// Create stores s together with its first price period, starting at s.StartDate.
+	Create(ctx context.Context, s *models.Subscription, audit models.AuditInfo) error
+	// Patch applies patch to the current state of a subscription, locked against
+	// concurrent changes, and returns the updated subscription. A price change is
+	// recorded as a new price period instead of repricing the months already
+	// charged. A version other than the current one fails with
+	// models.ErrVersionConflict. Errors returned by patch.Apply abort the change
+	// and are returned as is.
+	Patch(ctx context.Context, id int64, patch *models.SubscriptionPatch, audit models.AuditInfo) (*models.Subscription, error)
+	// Delete marks a subscription as deleted; it can be restored until purged.
+	Delete(ctx context.Context, id int64, audit models.AuditInfo) error
+	// CreateMany stores subscriptions like Create in one transaction: either
+	// all of them are stored or none.
+	CreateMany(ctx context.Context, subs []*models.Subscription, audit models.AuditInfo) error
+	// PatchMany applies patches like Patch in one transaction and in order, so
+	// that a later patch of a subscription sees the earlier ones. A failed item
+	// (a missing subscription, a version conflict or an error of its Apply) is
+	// skipped and its error returned at its index in errs; when atomic, any
+	// failed item rolls the whole batch back and updated holds only nils. err
+	// reports the failure of the batch as a whole.
+	PatchMany(ctx context.Context, items []PatchItem, audit models.AuditInfo, atomic bool) (updated []*models.Subscription, errs []error, err error)
+	// DeleteMany deletes subscriptions like Delete in one transaction, reporting
+	// failed items like PatchMany.
+	DeleteMany(ctx context.Context, ids []int64, audit models.AuditInfo, atomic bool) (errs []error, err error)
+	// FindDuplicates returns, at the index of each of subs, the id of a stored
+	// subscription that is not deleted and has the same user, service, monthly
+	// price and dates, or 0 when there is none.
+	FindDuplicates(ctx context.Context, subs []*models.Subscription) ([]int64, error)
+	// Restore undoes the deletion of a subscription. Subscriptions that are not
+	// deleted count as missing.
+	Restore(ctx context.Context, id int64, audit models.AuditInfo) error
+	// Purge permanently removes the subscriptions deleted before the given time
+	// and returns how many were removed.
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+	// GetPriceHistory returns the price periods of a subscription ordered by
+	// the month they take effect from.
+	GetPriceHistory(ctx context.Context, id int64) ([]models.PricePeriod, error)
+	// GetHistory returns the recorded changes of a subscription, oldest first,
+	// including those of purged subscriptions. A stored subscription without
+	// recorded changes, such as one created before the audit log, has an empty
+	// history; sql.ErrNoRows means there is no such subscription at all.
+	GetHistory(ctx context.Context, id int64) ([]models.AuditEntry, error)
+	// GetSubscriptionsInvoice returns the amounts billed per invoice group and
+	// currency, and per month when req.BilledPerMonth(), ordered by group.
+	GetSubscriptionsInvoice(ctx context.Context, req *models.SubscriptionInvoiceRequest) ([]models.InvoiceAmount, error)
+	GetSubscriptionsInvoiceDetails(ctx context.Context, req *models.SubscriptionInvoiceRequest) (*models.SubscriptionInvoiceDetails, error)
+	// EachSubscription calls fn with every subscription matching f in the order
+	// f sorts by, ignoring its page bounds. The subscriptions are read in
+	// batches rather than all at once. An error from fn stops the iteration and
+	// is returned as is.
+	EachSubscription(ctx context.Context, f *models.SubscriptionFilter, fn func(models.Subscription) error) error
+	// EachInvoiceLine calls fn with every month billed for the invoice, ordered
+	// by subscription and month, reading them like EachSubscription.
+	EachInvoiceLine(ctx context.Context, req *models.SubscriptionInvoiceRequest, fn func(models.InvoiceLine) error) error
+}
+
+// PatchItem is a patch of the subscription with the given id.
+type PatchItem struct {
+	Id    int64
+	Patch *models.SubscriptionPatch
+}
+
+// failed reports whether any item of a batch failed.
+func failed(errs []error) bool {
+	for _, err := range errs {
+		if err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// FindService looks a service up in services by id, or by name or alias when
+// no id is given. A missing service is reported as models.ErrUnknownService.
+func FindService(ctx context.Context, services ServiceRepository, id int64, name string) (*models.Service, error) {
+	var service *models.Service
+	var err error
+	if id != 0 {
+		service, err = services.GetServiceById(ctx, id)
+	} else {
+		service, err = services.ResolveService(ctx, name)
+	}
+	if err == sql.ErrNoRows {
+		return nil, models.ErrUnknownService
+	}
+	return service, err
+}
+
+// RateRepository stores monthly currency exchange rates.
+// Lookups of a missing rate return sql.ErrNoRows.
+type RateRepository interface {
+	GetRates(ctx context.Context, f *models.CurrencyRateFilter) ([]models.CurrencyRate, error)
+	// FindRate returns the latest rate of the pair for month or an earlier month.
+	FindRate(ctx context.Context, base, quote string, month models.MonthYear) (*models.CurrencyRate, error)
+	// SaveRates inserts or replaces the given rates atomically.
+	SaveRates(ctx context.Context, rates []models.CurrencyRate) error
+	DeleteRate(ctx context.Context, base, quote string, month models.MonthYear) error
+}
+
+// ServiceRepository stores the services catalogue.
+// Lookups of a missing service return sql.ErrNoRows.
+type ServiceRepository interface {
+	GetServices(ctx context.Context) ([]models.Service, error)
+	GetServiceById(ctx context.Context, id int64) (*models.Service, error)
+	// ResolveService finds the service whose name or alias matches name,
+	// ignoring case and extra whitespace.
+	ResolveService(ctx context.Context, name string) (*models.Service, error)
+	// CreateService and UpdateService return models.ErrServiceConflict when the
+	// name or an alias already belongs to another service. Renaming a service
+	// renames it on its subscriptions too, which counts as an update of each of
+	// them and is recorded in the audit log under audit.
+	CreateService(ctx context.Context, s *models.Service) error
+	UpdateService(ctx context.Context, s *models.Service, audit models.AuditInfo) error
+	// DeleteService returns models.ErrServiceInUse while subscriptions refer to the service.
+	DeleteService(ctx context.Context, id int64) error
+}
+
+// SchemaRepository reports on the database the other repositories are stored in.
+type SchemaRepository interface {
+	// Ping checks that the database can be reached.
+	Ping(ctx context.Context) error
+	// SchemaVersion returns the version of the last migration applied, 0 when
+	// none was, and whether it failed halfway and left the schema dirty.
+	SchemaVersion(ctx context.Context) (version uint, dirty bool, err error)
+}
@@ -0,0 +1,134 @@
+package routes
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/apierror"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/currency"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/helpers"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/repository"
+)
+
+type rateHandler struct {
+	repo repository.RateRepository
+}
+
+// @Summary Get currency rates
+// @Description Get the monthly currency rates matching the given filters
+// @Tags Rates
+// @Produce json
+// @Param base query string false "Base currency"
+// @Param quote query string false "Quote currency"
+// @Param from query string false "Earliest month (MM-YYYY)"
+// @Param to query string false "Latest month (MM-YYYY)"
+// @Success 200 {array} models.CurrencyRate
+// @Failure 400 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/admin/rates [get]
+func (h *rateHandler) getRates(ctx *gin.Context) {
+	var filter models.CurrencyRateFilter
+	if !helpers.BindQueryWithValidation(ctx, &filter) {
+		return
+	}
+	rates, err := h.repo.GetRates(ctx.Request.Context(), &filter)
+	if err != nil {
+		apierror.Respond(ctx, apierror.Internal(err, "could not fetch currency rates"))
+		return
+	}
+	ctx.JSON(http.StatusOK, rates)
+}
+
+// @Summary Save currency rate
+// @Description Create or replace the rate of a currency pair for a month
+// @Tags Rates
+// @Accept json
+// @Produce json
+// @Param rate body models.CurrencyRate true "Currency rate"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/admin/rates [put]
+func (h *rateHandler) saveRate(ctx *gin.Context) {
+	var rate models.CurrencyRate
+	if !helpers.BindJSONWithValidation(ctx, &rate) {
+		return
+	}
+	if err := h.repo.SaveRates(ctx.Request.Context(), []models.CurrencyRate{rate}); err != nil {
+		apierror.Respond(ctx, apierror.Internal(err, "could not save the currency rate"))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "The currency rate was successfully saved"})
+}
+
+// @Summary Import currency rates
+// @Description Create or replace currency rates from CSV with the header "base,quote,month,rate", months formatted as MM-YYYY. The CSV is sent as the request body or as the "file" field of a multipart form. Nothing is saved if any line is invalid.
+// @Tags Rates
+// @Accept text/csv
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file false "CSV file"
+// @Success 200 {object} map[string]int
+// @Failure 400 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/admin/rates/import [post]
+func (h *rateHandler) importRates(ctx *gin.Context) {
+	var body io.Reader = ctx.Request.Body
+	if strings.HasPrefix(ctx.ContentType(), "multipart/") {
+		file, err := ctx.FormFile("file")
+		if err != nil {
+			apierror.Respond(ctx, apierror.BadRequest("the file field must hold a CSV file"))
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			apierror.Respond(ctx, apierror.BadRequest("could not read the CSV file"))
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+	rates, err := currency.ParseRatesCSV(body)
+	if err != nil {
+		apierror.Respond(ctx, apierror.BadRequest(err.Error()))
+		return
+	}
+	if err := h.repo.SaveRates(ctx.Request.Context(), rates); err != nil {
+		apierror.Respond(ctx, apierror.Internal(err, "could not import currency rates"))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"imported": len(rates)})
+}
+
+// @Summary Delete currency rate
+// @Description Delete the rate of a currency pair for a month
+// @Tags Rates
+// @Param base path string true "Base currency"
+// @Param quote path string true "Quote currency"
+// @Param month path string true "Month (MM-YYYY)"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/admin/rates/{base}/{quote}/{month} [delete]
+func (h *rateHandler) deleteRate(ctx *gin.Context) {
+	month, err := time.Parse("01-2006", ctx.Param("month"))
+	if err != nil {
+		apierror.Respond(ctx, apierror.BadRequest("month must be formatted as MM-YYYY"))
+		return
+	}
+	err = h.repo.DeleteRate(ctx.Request.Context(), strings.ToUpper(ctx.Param("base")), strings.ToUpper(ctx.Param("quote")), models.FromTime(month))
+	if err == sql.ErrNoRows {
+		apierror.Respond(ctx, apierror.NotFound("currency rate not found"))
+		return
+	} else if err != nil {
+		apierror.Respond(ctx, apierror.Internal(err, "could not delete the currency rate"))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "The currency rate was successfully deleted"})
+}
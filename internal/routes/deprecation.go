@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// legacyDeprecatedAt is when the routes outside /api/v1 were deprecated.
+	legacyDeprecatedAt = time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC)
+	// legacySunset is when the routes outside /api/v1 are to be removed.
+	legacySunset = time.Date(2027, time.June, 30, 0, 0, 0, 0, time.UTC)
+)
+
+// deprecated marks the responses of a legacy route with the Deprecation
+// (RFC 9745) and Sunset (RFC 8594) headers and links the route that supersedes
+// it. Path parameters of the successor, such as :id, are filled in from the
+// request.
+func deprecated(successor string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		link := successor
+		for _, p := range ctx.Params {
+			link = strings.Replace(link, ":"+p.Key, p.Value, 1)
+		}
+		ctx.Header("Deprecation", fmt.Sprintf("@%d", legacyDeprecatedAt.Unix()))
+		ctx.Header("Sunset", legacySunset.Format(http.TimeFormat))
+		ctx.Header("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, link))
+		ctx.Next()
+	}
+}
@@ -0,0 +1,49 @@
+package routes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/apierror"
+)
+
+// errInvalidIfMatch is the problem of an If-Match header that is not the ETag
+// of a subscription version.
+var errInvalidIfMatch = apierror.BadRequest("If-Match must be the ETag of a subscription version")
+
+// etag is the entity tag of a subscription version.
+func etag(version int64) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// ifMatchVersion returns the subscription version required by the If-Match
+// header, or 0 when any version is acceptable. It reports false for tags that
+// are not subscription versions.
+func ifMatchVersion(ctx *gin.Context) (int64, bool) {
+	tag := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if tag == "" || tag == "*" {
+		return 0, true
+	}
+	tag = strings.TrimPrefix(tag, "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// notModified reports whether the If-None-Match header matches version.
+func notModified(ctx *gin.Context, version int64) bool {
+	for _, tag := range strings.Split(ctx.GetHeader("If-None-Match"), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag(version) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package routes
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/apierror"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/logger"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/repository"
+)
+
+// Health is the state of the service reported by the health endpoints, apart
+// from the state of its database.
+type Health struct {
+	// Version is the release of the service.
+	Version string
+	// SchemaVersion is the migration version the service expects the database at.
+	SchemaVersion uint
+	shuttingDown  atomic.Bool
+}
+
+// ShutDown makes the service report that it is no longer ready, so that no new
+// requests are sent to it while the running ones complete.
+func (h *Health) ShutDown() {
+	h.shuttingDown.Store(true)
+}
+
+// HealthStatus is the body of a successful health check.
+type HealthStatus struct {
+	// example: ok
+	Status string `json:"status"`
+}
+
+// VersionInfo describes the build of the service and the schema of its database.
+type VersionInfo struct {
+	// Release of the service
+	// example: 1.4.0
+	Version string `json:"version"`
+	// Commit the service was built from, when known
+	// example: 9f2c1e4b7a0d
+	Revision string `json:"revision,omitempty"`
+	// Time of that commit, when known
+	// example: 2026-10-01T12:00:00Z
+	RevisionTime string `json:"revision_time,omitempty"`
+	// Whether the service was built with uncommitted changes
+	Modified bool `json:"modified,omitempty"`
+	// example: go1.24.0
+	GoVersion string `json:"go_version"`
+	// Migration version the database is at, null when it cannot be read
+	// example: 1693551600
+	SchemaVersion *uint `json:"schema_version"`
+	// Whether the last migration failed halfway
+	SchemaDirty bool `json:"schema_dirty,omitempty"`
+	// Migration version the service expects the database at
+	// example: 1693551600
+	ExpectedSchemaVersion uint `json:"expected_schema_version"`
+}
+
+type healthHandler struct {
+	schema repository.SchemaRepository
+	health *Health
+	build  VersionInfo
+}
+
+func newHealthHandler(schema repository.SchemaRepository, health *Health) *healthHandler {
+	build := VersionInfo{Version: health.Version, ExpectedSchemaVersion: health.SchemaVersion}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		build.GoVersion = info.GoVersion
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				build.Revision = s.Value
+			case "vcs.time":
+				build.RevisionTime = s.Value
+			case "vcs.modified":
+				build.Modified = s.Value == "true"
+			}
+		}
+	}
+	return &healthHandler{schema: schema, health: health, build: build}
+}
+
+// @Summary Liveness check
+// @Description Succeeds while the process is running, whatever the state of the database
+// @Tags Health
+// @Produce json
+// @Success 200 {object} routes.HealthStatus
+// @Router /healthz [get]
+func (h *healthHandler) healthz(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, HealthStatus{Status: "ok"})
+}
+
+// @Summary Readiness check
+// @Description Succeeds while the service can serve requests: it is not shutting down, and its database is reachable and at the schema version the service expects
+// @Tags Health
+// @Produce json
+// @Success 200 {object} routes.HealthStatus
+// @Failure 503 {object} apierror.Problem
+// @Router /readyz [get]
+func (h *healthHandler) readyz(ctx *gin.Context) {
+	if h.health.shuttingDown.Load() {
+		apierror.Respond(ctx, apierror.NotReady(nil, "the service is shutting down"))
+		return
+	}
+	if err := h.schema.Ping(ctx.Request.Context()); err != nil {
+		apierror.Respond(ctx, apierror.NotReady(err, "the database is unreachable"))
+		return
+	}
+	version, dirty, err := h.schema.SchemaVersion(ctx.Request.Context())
+	if err != nil {
+		apierror.Respond(ctx, apierror.NotReady(err, "could not read the schema version"))
+		return
+	}
+	if dirty {
+		apierror.Respond(ctx, apierror.NotReady(nil, fmt.Sprintf("migration %d failed halfway", version)))
+		return
+	}
+	if version != h.health.SchemaVersion {
+		apierror.Respond(ctx, apierror.NotReady(nil,
+			fmt.Sprintf("the schema is at version %d, expected %d", version, h.health.SchemaVersion)))
+		return
+	}
+	ctx.JSON(http.StatusOK, HealthStatus{Status: "ready"})
+}
+
+// @Summary Version
+// @Description Get the build of the service and the migration version of its database
+// @Tags Health
+// @Produce json
+// @Success 200 {object} routes.VersionInfo
+// @Router /version [get]
+func (h *healthHandler) version(ctx *gin.Context) {
+	info := h.build
+	version, dirty, err := h.schema.SchemaVersion(ctx.Request.Context())
+	if err != nil {
+		logger.Log.Warn("could not read the schema version", slog.Any("err", err))
+	} else {
+		info.SchemaVersion, info.SchemaDirty = &version, dirty
+	}
+	ctx.JSON(http.StatusOK, info)
+}
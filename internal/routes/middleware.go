@@ -0,0 +1,37 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+)
+
+const (
+	requestIdHeader = "X-Request-ID"
+	actorHeader     = "X-Actor"
+	requestIdKey    = "request_id"
+	anonymousActor  = "anonymous"
+)
+
+// requestId keeps the request id sent by the client, or assigns a new one, and
+// echoes it in the response.
+func requestId() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(requestIdHeader)
+		if id == "" {
+			id = uuid.NewString()
+		}
+		ctx.Set(requestIdKey, id)
+		ctx.Header(requestIdHeader, id)
+		ctx.Next()
+	}
+}
+
+// auditInfo identifies the caller of the request for the audit log.
+func auditInfo(ctx *gin.Context) models.AuditInfo {
+	actor := ctx.GetHeader(actorHeader)
+	if actor == "" {
+		actor = anonymousActor
+	}
+	return models.AuditInfo{Actor: actor, RequestId: ctx.GetString(requestIdKey)}
+}
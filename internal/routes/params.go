@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/apierror"
+)
+
+// idParam parses the id path parameter, responding with a problem when it is
+// not a positive integer.
+func idParam(ctx *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		apierror.Respond(ctx, apierror.BadRequest("id must be a positive integer"))
+		return 0, false
+	}
+	return id, true
+}
@@ -1,6 +1,8 @@
 package routes
 
 import (
+	"fmt"
+
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
@@ -8,21 +10,119 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 
 	_ "github.com/mukashev-n/online-subscriptions-data-aggregator-service/docs"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/apierror"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/currency"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/repository"
 	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/validators"
 )
 
-func RegisterRoutes(server *gin.Engine) {
+// Repositories are the storages the routes are served from.
+type Repositories struct {
+	Subscriptions repository.SubscriptionRepository
+	Rates         repository.RateRepository
+	Services      repository.ServiceRepository
+	Schema        repository.SchemaRepository
+}
+
+func RegisterRoutes(server *gin.Engine, repos Repositories, health *Health) {
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		validators.RegisterValidators(v)
 	}
-	following := server.Group("/subscription")
+	server.Use(requestId(), gin.CustomRecovery(func(ctx *gin.Context, recovered any) {
+		apierror.Respond(ctx, apierror.Internal(fmt.Errorf("panic: %v", recovered), "internal error"))
+	}))
+	server.NoRoute(func(ctx *gin.Context) {
+		apierror.Respond(ctx, apierror.NotFound("route not found"))
+	})
+	h := &subscriptionHandler{
+		repo:      repos.Subscriptions,
+		services:  repos.Services,
+		converter: currency.NewRatesConverter(repos.Rates),
+	}
+	sh := &serviceHandler{repo: repos.Services}
+	rh := &rateHandler{repo: repos.Rates}
+	hh := newHealthHandler(repos.Schema, health)
+
+	server.GET("/healthz", hh.healthz)
+	server.GET("/readyz", hh.readyz)
+	server.GET("/version", hh.version)
+
+	v1 := server.Group("/api/v1")
 	{
-		following.GET("/:id", getById)
-		following.GET("/all", getAll)
-		following.POST("", create)
-		following.PUT("", update)
-		following.DELETE("/:id", delete)
-		following.POST("/invoice", getSubscriptionsInvoice)
+		subscriptions := v1.Group("/subscriptions")
+		subscriptions.GET("", h.getAll)
+		subscriptions.POST("", h.create)
+		subscriptions.POST("/bulk", h.bulkCreate)
+		subscriptions.PUT("/bulk", h.bulkUpdate)
+		subscriptions.POST("/bulk/delete", h.bulkDelete)
+		subscriptions.POST("/import", h.importSubscriptions)
+		subscriptions.GET("/export", h.exportSubscriptions)
+		subscriptions.GET("/:id", h.getById)
+		subscriptions.PUT("/:id", h.updateById)
+		subscriptions.PATCH("/:id", h.patch)
+		subscriptions.DELETE("/:id", h.delete)
+		subscriptions.POST("/:id/restore", h.restore)
+		subscriptions.GET("/:id/prices", h.getPriceHistory)
+		subscriptions.GET("/:id/history", h.getHistory)
+
+		invoices := v1.Group("/invoices")
+		invoices.POST("", h.getSubscriptionsInvoice)
+		invoices.POST("/details", h.getSubscriptionsInvoiceDetails)
+		invoices.POST("/export", h.exportInvoice)
+
+		v1.GET("/users/:user_id/subscriptions", h.getUserSubscriptions)
+
+		services := v1.Group("/services")
+		services.GET("", sh.getServices)
+		services.GET("/:id", sh.getServiceById)
+		services.POST("", sh.createService)
+		services.PUT("/:id", sh.updateService)
+		services.DELETE("/:id", sh.deleteService)
+
+		rates := v1.Group("/admin/rates")
+		rates.GET("", rh.getRates)
+		rates.PUT("", rh.saveRate)
+		rates.POST("/import", rh.importRates)
+		rates.DELETE("/:base/:quote/:month", rh.deleteRate)
 	}
+	registerLegacyRoutes(server, h, sh, rh)
 	server.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 }
+
+// registerLegacyRoutes keeps the routes that predate /api/v1 working as
+// deprecated aliases of their successors.
+func registerLegacyRoutes(server *gin.Engine, h *subscriptionHandler, sh *serviceHandler, rh *rateHandler) {
+	following := server.Group("/subscription")
+	{
+		following.GET("/:id", deprecated("/api/v1/subscriptions/:id"), h.getById)
+		following.GET("/:id/prices", deprecated("/api/v1/subscriptions/:id/prices"), h.getPriceHistory)
+		following.GET("/:id/history", deprecated("/api/v1/subscriptions/:id/history"), h.getHistory)
+		following.GET("/all", deprecated("/api/v1/subscriptions"), h.getAll)
+		following.POST("", deprecated("/api/v1/subscriptions"), h.create)
+		following.PUT("", deprecated("/api/v1/subscriptions/{id}"), h.update)
+		following.PATCH("/:id", deprecated("/api/v1/subscriptions/:id"), h.patch)
+		following.DELETE("/:id", deprecated("/api/v1/subscriptions/:id"), h.delete)
+		following.POST("/:id/restore", deprecated("/api/v1/subscriptions/:id/restore"), h.restore)
+		following.POST("/invoice", deprecated("/api/v1/invoices"), h.getSubscriptionsInvoice)
+		following.POST("/invoice/details", deprecated("/api/v1/invoices/details"), h.getSubscriptionsInvoiceDetails)
+	}
+	users := server.Group("/users")
+	{
+		users.GET("/:user_id/subscriptions", deprecated("/api/v1/users/:user_id/subscriptions"), h.getUserSubscriptions)
+	}
+	services := server.Group("/services")
+	{
+		services.GET("", deprecated("/api/v1/services"), sh.getServices)
+		services.GET("/:id", deprecated("/api/v1/services/:id"), sh.getServiceById)
+		services.POST("", deprecated("/api/v1/services"), sh.createService)
+		services.PUT("/:id", deprecated("/api/v1/services/:id"), sh.updateService)
+		services.DELETE("/:id", deprecated("/api/v1/services/:id"), sh.deleteService)
+	}
+	admin := server.Group("/admin/rates")
+	{
+		admin.GET("", deprecated("/api/v1/admin/rates"), rh.getRates)
+		admin.PUT("", deprecated("/api/v1/admin/rates"), rh.saveRate)
+		admin.POST("/import", deprecated("/api/v1/admin/rates/import"), rh.importRates)
+		admin.DELETE("/:base/:quote/:month", deprecated("/api/v1/admin/rates/:base/:quote/:month"), rh.deleteRate)
+	}
+}
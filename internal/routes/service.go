@@ -0,0 +1,152 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/apierror"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/helpers"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/repository"
+)
+
+type serviceHandler struct {
+	repo repository.ServiceRepository
+}
+
+// @Summary Get services
+// @Description Get all services of the catalogue ordered by name
+// @Tags Services
+// @Produce json
+// @Success 200 {array} models.Service
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/services [get]
+func (h *serviceHandler) getServices(ctx *gin.Context) {
+	services, err := h.repo.GetServices(ctx.Request.Context())
+	if err != nil {
+		apierror.Respond(ctx, apierror.Internal(err, "could not fetch services"))
+		return
+	}
+	ctx.JSON(http.StatusOK, services)
+}
+
+// @Summary Get service by ID
+// @Description Get a single service of the catalogue by its ID
+// @Tags Services
+// @Produce json
+// @Param id path int true "Service ID"
+// @Success 200 {object} models.Service
+// @Failure 400 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/services/{id} [get]
+func (h *serviceHandler) getServiceById(ctx *gin.Context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+	service, err := h.repo.GetServiceById(ctx.Request.Context(), id)
+	if err == sql.ErrNoRows {
+		apierror.Respond(ctx, apierror.NotFound("service not found"))
+		return
+	} else if err != nil {
+		apierror.Respond(ctx, apierror.Internal(err, "could not fetch the service"))
+		return
+	}
+	ctx.JSON(http.StatusOK, service)
+}
+
+// @Summary Create service
+// @Description Add a service to the catalogue. Its name and aliases must not match those of another service, ignoring case and extra whitespace
+// @Tags Services
+// @Accept json
+// @Produce json
+// @Param service body models.Service true "Service data"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} apierror.Problem
+// @Failure 409 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/services [post]
+func (h *serviceHandler) createService(ctx *gin.Context) {
+	var service models.Service
+	if !helpers.BindJSONWithValidation(ctx, &service) {
+		return
+	}
+	err := h.repo.CreateService(ctx.Request.Context(), &service)
+	if errors.Is(err, models.ErrServiceConflict) {
+		apierror.Respond(ctx, apierror.Conflict(err))
+		return
+	} else if err != nil {
+		apierror.Respond(ctx, apierror.Internal(err, "could not create the service"))
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"id": service.Id, "message": "New service was created"})
+}
+
+// @Summary Update service
+// @Description Replace a service of the catalogue. Renaming it renames its subscriptions as well, which changes their versions and is recorded in their history
+// @Tags Services
+// @Accept json
+// @Produce json
+// @Param id path int true "Service ID"
+// @Param service body models.Service true "Service data"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 409 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/services/{id} [put]
+func (h *serviceHandler) updateService(ctx *gin.Context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+	var service models.Service
+	if !helpers.BindJSONWithValidation(ctx, &service) {
+		return
+	}
+	service.Id = id
+	err := h.repo.UpdateService(ctx.Request.Context(), &service, auditInfo(ctx))
+	if err == sql.ErrNoRows {
+		apierror.Respond(ctx, apierror.NotFound("service not found"))
+		return
+	} else if errors.Is(err, models.ErrServiceConflict) {
+		apierror.Respond(ctx, apierror.Conflict(err))
+		return
+	} else if err != nil {
+		apierror.Respond(ctx, apierror.Internal(err, "could not update the service"))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "The service was successfully updated"})
+}
+
+// @Summary Delete service
+// @Description Remove a service from the catalogue. Services still used by subscriptions cannot be deleted
+// @Tags Services
+// @Param id path int true "Service ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 409 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/services/{id} [delete]
+func (h *serviceHandler) deleteService(ctx *gin.Context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+	err := h.repo.DeleteService(ctx.Request.Context(), id)
+	if err == sql.ErrNoRows {
+		apierror.Respond(ctx, apierror.NotFound("service not found"))
+		return
+	} else if errors.Is(err, models.ErrServiceInUse) {
+		apierror.Respond(ctx, apierror.Conflict(err))
+		return
+	} else if err != nil {
+		apierror.Respond(ctx, apierror.Internal(err, "could not delete the service"))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "The service was successfully deleted"})
+}
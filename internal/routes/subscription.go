@@ -1,63 +1,170 @@
 package routes
 
 import (
+	"context"
 	"database/sql"
-	"log/slog"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/apierror"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/currency"
 	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/helpers"
-	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/logger"
 	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/repository"
 )
 
+type subscriptionHandler struct {
+	repo      repository.SubscriptionRepository
+	services  repository.ServiceRepository
+	converter *currency.RatesConverter
+}
+
 // @Summary Get subscription by ID
 // @Description Get a single subscription by its ID
 // @Tags Subscription
 // @Param id path int true "Subscription ID"
+// @Param include_deleted query bool false "Whether a deleted subscription is returned too"
+// @Param If-None-Match header string false "ETag of a cached version"
 // @Success 200 {object} models.Subscription
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /subscription/{id} [get]
-func getById(ctx *gin.Context) {
-	idParam := ctx.Param("id")
-	if idParam == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": "You need to provide an ID"})
+// @Header 200 {string} ETag "Version of the subscription"
+// @Success 304
+// @Failure 400 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/subscriptions/{id} [get]
+func (h *subscriptionHandler) getById(ctx *gin.Context) {
+	id, ok := idParam(ctx)
+	if !ok {
 		return
 	}
-	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
-	if err != nil {
-		logger.Log.Error("Could not parse id", slog.Any("err", err))
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse id"})
+	includeDeleted, _ := strconv.ParseBool(ctx.Query("include_deleted"))
+	subscription, err := h.repo.GetById(ctx.Request.Context(), id, includeDeleted)
+	if err == sql.ErrNoRows {
+		apierror.Respond(ctx, apierror.NotFound("subscription not found"))
+		return
+	} else if err != nil {
+		apierror.Respond(ctx, apierror.Internal(err, "could not fetch the subscription"))
+		return
+	}
+
+	ctx.Header("ETag", etag(subscription.Version))
+	if notModified(ctx, subscription.Version) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+	ctx.JSON(http.StatusOK, subscription)
+}
+
+// @Summary Get subscription price history
+// @Description Get the monthly prices of a subscription and the months they took effect from
+// @Tags Subscription
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Success 200 {array} models.PricePeriod
+// @Failure 400 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/subscriptions/{id}/prices [get]
+func (h *subscriptionHandler) getPriceHistory(ctx *gin.Context) {
+	id, ok := idParam(ctx)
+	if !ok {
 		return
 	}
-	subscription, err := models.GetById(id)
+	periods, err := h.repo.GetPriceHistory(ctx.Request.Context(), id)
 	if err == sql.ErrNoRows {
-		ctx.JSON(http.StatusNotFound, gin.H{"message": "Subscription not found"})
+		apierror.Respond(ctx, apierror.NotFound("subscription not found"))
 		return
 	} else if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch subscription"})
+		apierror.Respond(ctx, apierror.Internal(err, "could not fetch the price history"))
 		return
 	}
+	ctx.JSON(http.StatusOK, periods)
+}
 
-	ctx.JSON(http.StatusOK, subscription)
+// @Summary Get subscription history
+// @Description Get the recorded changes of a subscription, oldest first, with snapshots before and after each change. Purged subscriptions keep their history
+// @Tags Subscription
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Success 200 {array} models.AuditEntry
+// @Failure 400 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/subscriptions/{id}/history [get]
+func (h *subscriptionHandler) getHistory(ctx *gin.Context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+	entries, err := h.repo.GetHistory(ctx.Request.Context(), id)
+	if err == sql.ErrNoRows {
+		apierror.Respond(ctx, apierror.NotFound("subscription history not found"))
+		return
+	} else if err != nil {
+		apierror.Respond(ctx, apierror.Internal(err, "could not fetch the subscription history"))
+		return
+	}
+	ctx.JSON(http.StatusOK, entries)
 }
 
 // @Summary Get all subscriptions
-// @Description Get a list of all subscriptions
+// @Description Get a page of subscriptions matching the given filters
 // @Tags Subscription
-// @Success 200 {array} models.Subscription
-// @Failure 500 {object} map[string]string
-// @Router /subscription/all [get]
-func getAll(ctx *gin.Context) {
-	subscriptions, err := models.GetAll()
+// @Produce json
+// @Param user_id query string false "User ID"
+// @Param service_id query int false "Service ID"
+// @Param service_name query string false "Service name or alias"
+// @Param currency query string false "Currency of the monthly price"
+// @Param min_price query int false "Minimal monthly price in minor units"
+// @Param max_price query int false "Maximal monthly price in minor units"
+// @Param active_at query string false "Month the subscription is active in (MM-YYYY)"
+// @Param start_from query string false "Earliest start date (MM-YYYY)"
+// @Param start_to query string false "Latest start date (MM-YYYY)"
+// @Param end_from query string false "Earliest end date (MM-YYYY)"
+// @Param end_to query string false "Latest end date (MM-YYYY)"
+// @Param sort_by query string false "Sort column" Enums(id, service_name, monthly_price, start_date, end_date)
+// @Param order query string false "Sort direction" Enums(asc, desc)
+// @Param cursor query string false "next_cursor of the previous page, valid with the same sort_by"
+// @Param limit query int false "Page size (1-100, default 20)"
+// @Param offset query int false "Number of subscriptions to skip"
+// @Param include_deleted query bool false "Whether deleted subscriptions are listed too"
+// @Success 200 {object} models.SubscriptionPage
+// @Failure 400 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/subscriptions [get]
+func (h *subscriptionHandler) getAll(ctx *gin.Context) {
+	var filter models.SubscriptionFilter
+	if !helpers.BindQueryWithValidation(ctx, &filter) {
+		return
+	}
+	if filter.Cursor != "" && filter.Offset != 0 {
+		apierror.Respond(ctx, apierror.BadRequest("cursor and offset cannot be used together"))
+		return
+	}
+	filter.SetDefaults()
+	if err := filter.ParseCursor(); err != nil {
+		apierror.Respond(ctx, apierror.BadRequest(err.Error()))
+		return
+	}
+	found, err := h.resolveServiceFilter(ctx.Request.Context(), &filter.ServiceId, filter.ServiceName)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch all subscriptions"})
+		apierror.Respond(ctx, apierror.Internal(err, "could not resolve the service"))
 		return
 	}
-	ctx.JSON(http.StatusOK, subscriptions)
+	if !found {
+		ctx.JSON(http.StatusOK, &models.SubscriptionPage{Items: []models.Subscription{}})
+		return
+	}
+	page, err := h.repo.GetAll(ctx.Request.Context(), &filter)
+	if err != nil {
+		apierror.Respond(ctx, apierror.Internal(err, "could not fetch subscriptions"))
+		return
+	}
+	ctx.JSON(http.StatusOK, page)
 }
 
 // @Summary Create new subscription
@@ -67,78 +174,213 @@ func getAll(ctx *gin.Context) {
 // @Produce json
 // @Param subscription body models.Subscription true "Subscription data"
 // @Success 201 {object} map[string]interface{}
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /subscription [post]
-func create(ctx *gin.Context) {
+// @Failure 400 {object} apierror.Problem
+// @Failure 422 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/subscriptions [post]
+func (h *subscriptionHandler) create(ctx *gin.Context) {
 	var subscription models.Subscription
 	if !helpers.BindJSONWithValidation(ctx, &subscription) {
 		return
 	}
-	err := subscription.Create()
+	service, err := h.resolveService(ctx.Request.Context(), subscription.ServiceId, subscription.ServiceName)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"message": "Could not create the new subscription"})
+		serviceError(ctx, err)
 		return
 	}
+	subscription.ServiceId, subscription.ServiceName = service.Id, service.Name
+	err = h.repo.Create(ctx.Request.Context(), &subscription, auditInfo(ctx))
+	if err != nil {
+		apierror.Respond(ctx, apierror.Internal(err, "could not create the subscription"))
+		return
+	}
+	ctx.Header("ETag", etag(subscription.Version))
 	ctx.JSON(http.StatusCreated, gin.H{"id": subscription.Id, "message": "New subscription was created"})
 }
 
 // @Summary Update subscription
-// @Description Update an existing subscription by ID
+// @Description Update an existing subscription by ID. A new monthly_price takes effect from price_effective_from, the current month by default, so earlier months keep their prices. Send the ETag of the version the update is based on in If-Match, or the version in the body, to have the update rejected with 409 when the subscription has changed since. The updated subscription is validated as a whole and rejected with 422 when, for example, the new end_date is before the stored start_date
+// @Tags Subscription
+// @Accept json
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Param subscription body models.UpdateSubscription true "Subscription data. The id may be omitted and must match the path otherwise"
+// @Param If-Match header string false "ETag of the version the update is based on"
+// @Success 200 {object} map[string]string
+// @Header 200 {string} ETag "Version of the updated subscription"
+// @Failure 400 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 409 {object} apierror.Problem
+// @Failure 422 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/subscriptions/{id} [put]
+func (h *subscriptionHandler) updateById(ctx *gin.Context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+	var subscription models.UpdateSubscription
+	if !helpers.BindJSONForPath(ctx, &subscription, func() error {
+		if subscription.Id != 0 && subscription.Id != id {
+			return errors.New("id in the body does not match the path")
+		}
+		subscription.Id = id
+		return nil
+	}) {
+		return
+	}
+	h.save(ctx, &subscription)
+}
+
+// @Summary Update subscription
+// @Description Update an existing subscription by the ID in the body. Superseded by PUT /api/v1/subscriptions/{id}
 // @Tags Subscription
 // @Accept json
 // @Produce json
 // @Param subscription body models.UpdateSubscription true "Subscription data"
+// @Param If-Match header string false "ETag of the version the update is based on"
 // @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Header 200 {string} ETag "Version of the updated subscription"
+// @Failure 400 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 409 {object} apierror.Problem
+// @Failure 422 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Deprecated
 // @Router /subscription [put]
-func update(ctx *gin.Context) {
+func (h *subscriptionHandler) update(ctx *gin.Context) {
 	var subscription models.UpdateSubscription
 	if !helpers.BindJSONWithValidation(ctx, &subscription) {
 		return
 	}
-	err := subscription.Update()
+	h.save(ctx, &subscription)
+}
+
+func (h *subscriptionHandler) save(ctx *gin.Context, subscription *models.UpdateSubscription) {
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		apierror.Respond(ctx, errInvalidIfMatch)
+		return
+	}
+	if version != 0 {
+		subscription.Version = version
+	}
+	if subscription.ServiceId != 0 || subscription.ServiceName != "" {
+		service, err := h.resolveService(ctx.Request.Context(), subscription.ServiceId, subscription.ServiceName)
+		if err != nil {
+			serviceError(ctx, err)
+			return
+		}
+		subscription.ServiceId, subscription.ServiceName = service.Id, service.Name
+	}
+	updated, err := h.repo.Patch(ctx.Request.Context(), subscription.Id, validated(subscription.Patch()), auditInfo(ctx))
 	if err != nil {
+		var verr validator.ValidationErrors
 		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, gin.H{"message": "Subscription was not found with given ID"})
+			apierror.Respond(ctx, apierror.NotFound("subscription not found"))
+		} else if errors.Is(err, models.ErrVersionConflict) {
+			apierror.Respond(ctx, apierror.Conflict(err))
+		} else if errors.As(err, &verr) {
+			helpers.RespondInvalid(ctx, &models.Subscription{}, verr, http.StatusUnprocessableEntity)
 		} else {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"message": "Could not update the subscription"})
+			apierror.Respond(ctx, apierror.Internal(err, "could not update the subscription"))
 		}
 		return
 	}
+	ctx.Header("ETag", etag(updated.Version))
 	ctx.JSON(http.StatusOK, gin.H{"message": "The subscription was successfully updated"})
 }
 
+// validated makes patch fail with the validation errors of the subscription it
+// results in, so that an update is checked against the fields it leaves as is.
+func validated(patch *models.SubscriptionPatch) *models.SubscriptionPatch {
+	apply := patch.Apply
+	patch.Apply = func(s *models.Subscription) error {
+		if err := apply(s); err != nil {
+			return err
+		}
+		return binding.Validator.ValidateStruct(s)
+	}
+	return patch
+}
+
 // @Summary Delete subscription
-// @Description Delete a subscription by its ID
+// @Description Delete a subscription by its ID. It is kept, excluded from listings and invoices, and can be restored until the purge job removes it
 // @Tags Subscription
 // @Param id path int true "Subscription ID"
 // @Success 200 {object} map[string]string
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /subscription/{id} [delete]
-func delete(ctx *gin.Context) {
-	idParam := ctx.Param("id")
-	if idParam == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": "You need to provide an ID"})
+// @Failure 400 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/subscriptions/{id} [delete]
+func (h *subscriptionHandler) delete(ctx *gin.Context) {
+	id, ok := idParam(ctx)
+	if !ok {
 		return
 	}
-	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
-	if err != nil {
-		logger.Log.Error("Could not parse id", slog.Any("err", err))
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": "Could not parse int"})
+	err := h.repo.Delete(ctx.Request.Context(), id, auditInfo(ctx))
+	if err == sql.ErrNoRows {
+		apierror.Respond(ctx, apierror.NotFound("subscription not found"))
+		return
+	} else if err != nil {
+		apierror.Respond(ctx, apierror.Internal(err, "could not delete the subscription"))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "The subscription was successfully deleted"})
+}
+
+// @Summary Restore subscription
+// @Description Restore a deleted subscription that has not been purged yet
+// @Tags Subscription
+// @Param id path int true "Subscription ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/subscriptions/{id}/restore [post]
+func (h *subscriptionHandler) restore(ctx *gin.Context) {
+	id, ok := idParam(ctx)
+	if !ok {
 		return
 	}
-	err = models.Delete(id)
+	err := h.repo.Restore(ctx.Request.Context(), id, auditInfo(ctx))
 	if err == sql.ErrNoRows {
-		ctx.JSON(http.StatusNotFound, gin.H{"message": "Subscription not found"})
+		apierror.Respond(ctx, apierror.NotFound("deleted subscription not found"))
 		return
 	} else if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"message": "Could not delete the subscription"})
+		apierror.Respond(ctx, apierror.Internal(err, "could not restore the subscription"))
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"message": "The subscription was successfully deleted"})
+	ctx.JSON(http.StatusOK, gin.H{"message": "The subscription was successfully restored"})
+}
+
+// resolveService looks a service up in the catalogue by id, or by name or alias
+// when no id is given.
+func (h *subscriptionHandler) resolveService(ctx context.Context, id int64, name string) (*models.Service, error) {
+	return repository.FindService(ctx, h.services, id, name)
+}
+
+// resolveServiceFilter turns a service name filter into a service id filter. It
+// reports false when no service matches, as then no subscription can match either.
+func (h *subscriptionHandler) resolveServiceFilter(ctx context.Context, serviceId *int64, serviceName string) (bool, error) {
+	if *serviceId != 0 || serviceName == "" {
+		return true, nil
+	}
+	service, err := h.resolveService(ctx, 0, serviceName)
+	if errors.Is(err, models.ErrUnknownService) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	*serviceId = service.Id
+	return true, nil
+}
+
+func serviceError(ctx *gin.Context, err error) {
+	if errors.Is(err, models.ErrUnknownService) {
+		apierror.Respond(ctx, apierror.Unprocessable(err))
+		return
+	}
+	apierror.Respond(ctx, apierror.Internal(err, "could not resolve the service"))
 }
@@ -0,0 +1,222 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/apierror"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/logger"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/repository"
+)
+
+const testUserId = "f47ac10b-58cc-4372-a567-0e02b2c3d479"
+
+// newTestServer returns a server over the memory repositories with the
+// service Netflix in the catalogue.
+func newTestServer(t *testing.T) *gin.Engine {
+	t.Helper()
+	logger.InitLogger("local")
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	subs := repository.NewMemorySubscriptionRepository()
+	RegisterRoutes(server, Repositories{
+		Subscriptions: subs,
+		Rates:         repository.NewMemoryRateRepository(),
+		Services:      repository.NewMemoryServiceRepository(subs),
+		Schema:        repository.NewMemorySchemaRepository(1),
+	}, &Health{Version: "test", SchemaVersion: 1})
+	if w := serve(server, http.MethodPost, "/api/v1/services", `{"name":"Netflix"}`); w.Code != http.StatusCreated {
+		t.Fatalf("create service: %d %s", w.Code, w.Body)
+	}
+	return server
+}
+
+func serve(server *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	return serveContent(server, method, path, "application/json", body)
+}
+
+func serveContent(server *gin.Engine, method, path, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	return w
+}
+
+// expect fails the test unless w has status and decodes its body into v.
+func expect(t *testing.T, w *httptest.ResponseRecorder, status int, v any) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("status = %d, want %d: %s", w.Code, status, w.Body)
+	}
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("decode %s: %v", w.Body, err)
+		}
+	}
+}
+
+func TestSubscriptionLifecycle(t *testing.T) {
+	server := newTestServer(t)
+
+	var created struct{ Id int64 }
+	expect(t, serve(server, http.MethodPost, "/api/v1/subscriptions",
+		`{"service_name":"netflix","monthly_price":{"amount":39900,"currency":"RUB"},"user_id":"`+testUserId+`","start_date":"01-2025"}`),
+		http.StatusCreated, &created)
+	if created.Id == 0 {
+		t.Fatal("created subscription has no id")
+	}
+	path := "/api/v1/subscriptions/" + strconv.FormatInt(created.Id, 10)
+
+	var got models.Subscription
+	expect(t, serve(server, http.MethodGet, path, ""), http.StatusOK, &got)
+	if got.ServiceName != "Netflix" || got.MonthlyPrice.Amount != 39900 || got.UserId.String() != testUserId || got.Version != 1 {
+		t.Errorf("got %+v", got)
+	}
+
+	var page models.SubscriptionPage
+	expect(t, serve(server, http.MethodGet, "/api/v1/subscriptions?user_id="+testUserId, ""), http.StatusOK, &page)
+	if page.TotalCount != 1 || len(page.Items) != 1 || page.Items[0].Id != created.Id {
+		t.Errorf("listing = %+v", page)
+	}
+
+	expect(t, serve(server, http.MethodPut, path, `{"end_date":"12-2025"}`), http.StatusOK, nil)
+	expect(t, serve(server, http.MethodGet, path, ""), http.StatusOK, &got)
+	if got.EndDate == nil || got.EndDate.String() != "12-2025" || got.Version != 2 {
+		t.Errorf("after update got %+v", got)
+	}
+
+	expect(t, serve(server, http.MethodDelete, path, ""), http.StatusOK, nil)
+	expect(t, serve(server, http.MethodGet, path, ""), http.StatusNotFound, nil)
+	expect(t, serve(server, http.MethodDelete, path, ""), http.StatusNotFound, nil)
+	page = models.SubscriptionPage{}
+	expect(t, serve(server, http.MethodGet, "/api/v1/subscriptions", ""), http.StatusOK, &page)
+	if page.TotalCount != 0 || len(page.Items) != 0 {
+		t.Errorf("listing after delete = %+v", page)
+	}
+}
+
+func TestSubscriptionErrors(t *testing.T) {
+	server := newTestServer(t)
+	expect(t, serve(server, http.MethodPost, "/api/v1/subscriptions",
+		`{"service_name":"Netflix","monthly_price":{"amount":39900,"currency":"RUB"},"user_id":"`+testUserId+`","start_date":"06-2025"}`),
+		http.StatusCreated, nil)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   apierror.Code
+		fields []string
+	}{
+		{
+			name:   "create with malformed JSON",
+			method: http.MethodPost, path: "/api/v1/subscriptions", body: `{bad`,
+			status: http.StatusBadRequest, code: apierror.CodeBadRequest,
+		},
+		{
+			name:   "create with invalid fields",
+			method: http.MethodPost, path: "/api/v1/subscriptions",
+			body:   `{"monthly_price":{"amount":0,"currency":"RUB"},"user_id":"` + testUserId + `","start_date":"01-2025"}`,
+			status: http.StatusBadRequest, code: apierror.CodeValidation,
+			fields: []string{"service_name", "monthly_price.amount"},
+		},
+		{
+			name:   "create with an unknown currency",
+			method: http.MethodPost, path: "/api/v1/subscriptions",
+			body:   `{"service_name":"Netflix","monthly_price":{"amount":100,"currency":"XYZ"},"user_id":"` + testUserId + `","start_date":"01-2025"}`,
+			status: http.StatusBadRequest, code: apierror.CodeValidation,
+			fields: []string{"monthly_price.currency"},
+		},
+		{
+			name:   "create with an unknown service",
+			method: http.MethodPost, path: "/api/v1/subscriptions",
+			body:   `{"service_name":"Hulu","monthly_price":{"amount":100,"currency":"RUB"},"user_id":"` + testUserId + `","start_date":"01-2025"}`,
+			status: http.StatusUnprocessableEntity, code: apierror.CodeValidation,
+		},
+		{
+			name:   "create with an invalid date",
+			method: http.MethodPost, path: "/api/v1/subscriptions",
+			body:   `{"service_name":"Netflix","monthly_price":{"amount":100,"currency":"RUB"},"user_id":"` + testUserId + `","start_date":"13-2025"}`,
+			status: http.StatusBadRequest, code: apierror.CodeBadRequest,
+		},
+		{
+			name:   "get with an invalid id",
+			method: http.MethodGet, path: "/api/v1/subscriptions/abc",
+			status: http.StatusBadRequest, code: apierror.CodeBadRequest,
+		},
+		{
+			name:   "get a missing subscription",
+			method: http.MethodGet, path: "/api/v1/subscriptions/42",
+			status: http.StatusNotFound, code: apierror.CodeNotFound,
+		},
+		{
+			name:   "list with an invalid filter",
+			method: http.MethodGet, path: "/api/v1/subscriptions?user_id=nope&sort_by=price",
+			status: http.StatusBadRequest, code: apierror.CodeValidation,
+			fields: []string{"user_id", "sort_by"},
+		},
+		{
+			name:   "list with an invalid cursor",
+			method: http.MethodGet, path: "/api/v1/subscriptions?cursor=nope",
+			status: http.StatusBadRequest, code: apierror.CodeBadRequest,
+		},
+		{
+			name:   "update with a mismatched id",
+			method: http.MethodPut, path: "/api/v1/subscriptions/1", body: `{"id":2}`,
+			status: http.StatusBadRequest, code: apierror.CodeBadRequest,
+		},
+		{
+			name:   "update ending before the start",
+			method: http.MethodPut, path: "/api/v1/subscriptions/1", body: `{"end_date":"01-2025"}`,
+			status: http.StatusUnprocessableEntity, code: apierror.CodeValidation,
+			fields: []string{"end_date"},
+		},
+		{
+			name:   "update a stale version",
+			method: http.MethodPut, path: "/api/v1/subscriptions/1", body: `{"version":7,"end_date":"12-2025"}`,
+			status: http.StatusConflict, code: apierror.CodeConflict,
+		},
+		{
+			name:   "update a missing subscription",
+			method: http.MethodPut, path: "/api/v1/subscriptions/42", body: `{"end_date":"12-2025"}`,
+			status: http.StatusNotFound, code: apierror.CodeNotFound,
+		},
+		{
+			name:   "delete with an invalid id",
+			method: http.MethodDelete, path: "/api/v1/subscriptions/0",
+			status: http.StatusBadRequest, code: apierror.CodeBadRequest,
+		},
+		{
+			name:   "delete a missing subscription",
+			method: http.MethodDelete, path: "/api/v1/subscriptions/42",
+			status: http.StatusNotFound, code: apierror.CodeNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(server, tt.method, tt.path, tt.body)
+			if ct := w.Header().Get("Content-Type"); ct != apierror.ContentType {
+				t.Errorf("Content-Type = %q, want %q", ct, apierror.ContentType)
+			}
+			var problem apierror.Problem
+			expect(t, w, tt.status, &problem)
+			if problem.Code != tt.code {
+				t.Errorf("code = %q, want %q", problem.Code, tt.code)
+			}
+			for _, field := range tt.fields {
+				if _, ok := problem.Errors[field]; !ok {
+					t.Errorf("no error for %s in %v", field, problem.Errors)
+				}
+			}
+		})
+	}
+}
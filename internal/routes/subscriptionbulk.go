@@ -0,0 +1,407 @@
+package routes
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/apierror"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/helpers"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/repository"
+)
+
+const (
+	// maxBulkItems limits the items of one bulk request, so that a batch
+	// fits in one transaction of reasonable size.
+	maxBulkItems = 10000
+	ndjsonType   = "application/x-ndjson"
+)
+
+// BulkResult is the outcome of a bulk request, item by item.
+// @Description Outcome of a bulk request
+type BulkResult struct {
+	// Number of items carried out
+	// example: 2
+	Succeeded int `json:"succeeded"`
+	// Number of items that failed
+	// example: 1
+	Failed int `json:"failed"`
+	// Outcome of each item, in the order of the request
+	Items []BulkItem `json:"items"`
+}
+
+// BulkItem is the outcome of one item of a bulk request.
+type BulkItem struct {
+	// Position of the item in the request, starting at 0
+	// example: 0
+	Index int `json:"index"`
+	// ID of the subscription
+	// example: 1
+	Id int64 `json:"id,omitempty"`
+	// Version of the subscription after the change
+	// example: 2
+	Version int64 `json:"version,omitempty"`
+	// HTTP status the item would have been answered with on its own
+	// example: 201
+	Status int `json:"status"`
+	// Problem of a failed item
+	Error *apierror.Problem `json:"error,omitempty"`
+}
+
+// @Summary Create subscriptions in bulk
+// @Description Create many subscriptions in one transaction from a JSON array or from NDJSON (application/x-ndjson), one subscription per line. By default the batch is atomic: when any item is invalid nothing is saved and the failed items are listed in a 422 problem, keyed by their index. With atomic=false the valid items are saved and the outcome of every item is returned
+// @Tags Subscription
+// @Accept json
+// @Accept application/x-ndjson
+// @Produce json
+// @Param subscriptions body []models.Subscription true "Subscriptions to create, at most 10000"
+// @Param atomic query bool false "Whether any failed item fails the whole batch (default true)"
+// @Success 200 {object} routes.BulkResult "Outcome of a batch that is not atomic"
+// @Success 201 {object} routes.BulkResult
+// @Failure 400 {object} apierror.Problem
+// @Failure 413 {object} apierror.Problem
+// @Failure 415 {object} apierror.Problem
+// @Failure 422 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/subscriptions/bulk [post]
+func (h *subscriptionHandler) bulkCreate(ctx *gin.Context) {
+	atomic, raw, ok := readBulk(ctx)
+	if !ok {
+		return
+	}
+	errs := make([]*apierror.Error, len(raw))
+	subs := make([]*models.Subscription, len(raw))
+	resolve := h.serviceResolver(ctx.Request.Context())
+	for i, item := range raw {
+		var s models.Subscription
+		if errs[i] = decodeBulkItem(item, &s); errs[i] != nil {
+			continue
+		}
+		service, err := resolve(s.ServiceId, s.ServiceName)
+		if errors.Is(err, models.ErrUnknownService) {
+			errs[i] = apierror.Unprocessable(err)
+			continue
+		} else if err != nil {
+			apierror.Respond(ctx, apierror.Internal(err, "could not resolve the service"))
+			return
+		}
+		s.ServiceId, s.ServiceName = service.Id, service.Name
+		subs[i] = &s
+	}
+	if atomic && bulkFailed(errs) {
+		apierror.Respond(ctx, bulkError(errs))
+		return
+	}
+	valid := make([]*models.Subscription, 0, len(subs))
+	for _, s := range subs {
+		if s != nil {
+			valid = append(valid, s)
+		}
+	}
+	if err := h.repo.CreateMany(ctx.Request.Context(), valid, auditInfo(ctx)); err != nil {
+		apierror.Respond(ctx, apierror.Internal(err, "could not create the subscriptions"))
+		return
+	}
+	result := newBulkResult(errs)
+	for i, s := range subs {
+		if s != nil {
+			result.Items[i] = BulkItem{Index: i, Id: s.Id, Version: s.Version, Status: http.StatusCreated}
+		}
+	}
+	respondBulk(ctx, atomic, http.StatusCreated, result)
+}
+
+// @Summary Update subscriptions in bulk
+// @Description Update many subscriptions like PUT /api/v1/subscriptions/{id} in one transaction, from a JSON array or NDJSON of updates carrying their id. Updates are applied in order, so a later update of the same subscription sees the earlier ones. A version in an update is checked like If-Match. Atomic and per-item modes work as in the bulk creation
+// @Tags Subscription
+// @Accept json
+// @Accept application/x-ndjson
+// @Produce json
+// @Param subscriptions body []models.UpdateSubscription true "Updates, at most 10000"
+// @Param atomic query bool false "Whether any failed item fails the whole batch (default true)"
+// @Success 200 {object} routes.BulkResult
+// @Failure 400 {object} apierror.Problem
+// @Failure 413 {object} apierror.Problem
+// @Failure 415 {object} apierror.Problem
+// @Failure 422 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/subscriptions/bulk [put]
+func (h *subscriptionHandler) bulkUpdate(ctx *gin.Context) {
+	atomic, raw, ok := readBulk(ctx)
+	if !ok {
+		return
+	}
+	errs := make([]*apierror.Error, len(raw))
+	items := make([]repository.PatchItem, 0, len(raw))
+	indexes := make([]int, 0, len(raw))
+	resolve := h.serviceResolver(ctx.Request.Context())
+	for i, item := range raw {
+		var u models.UpdateSubscription
+		if errs[i] = decodeBulkItem(item, &u); errs[i] != nil {
+			continue
+		}
+		if u.ServiceId != 0 || u.ServiceName != "" {
+			service, err := resolve(u.ServiceId, u.ServiceName)
+			if errors.Is(err, models.ErrUnknownService) {
+				errs[i] = apierror.Unprocessable(err)
+				continue
+			} else if err != nil {
+				apierror.Respond(ctx, apierror.Internal(err, "could not resolve the service"))
+				return
+			}
+			u.ServiceId, u.ServiceName = service.Id, service.Name
+		}
+		items = append(items, repository.PatchItem{Id: u.Id, Patch: validated(u.Patch())})
+		indexes = append(indexes, i)
+	}
+	if atomic && bulkFailed(errs) {
+		apierror.Respond(ctx, bulkError(errs))
+		return
+	}
+	updated, patchErrs, err := h.repo.PatchMany(ctx.Request.Context(), items, auditInfo(ctx), atomic)
+	if err != nil {
+		apierror.Respond(ctx, apierror.Internal(err, "could not update the subscriptions"))
+		return
+	}
+	for j, i := range indexes {
+		if patchErrs[j] != nil {
+			errs[i] = itemError(patchErrs[j])
+		}
+	}
+	if atomic && bulkFailed(errs) {
+		apierror.Respond(ctx, bulkError(errs))
+		return
+	}
+	result := newBulkResult(errs)
+	for j, i := range indexes {
+		if s := updated[j]; s != nil {
+			result.Items[i] = BulkItem{Index: i, Id: s.Id, Version: s.Version, Status: http.StatusOK}
+		}
+	}
+	respondBulk(ctx, atomic, http.StatusOK, result)
+}
+
+// @Summary Delete subscriptions in bulk
+// @Description Delete many subscriptions like DELETE /api/v1/subscriptions/{id} in one transaction, from a JSON array or NDJSON of ids. Atomic and per-item modes work as in the bulk creation
+// @Tags Subscription
+// @Accept json
+// @Accept application/x-ndjson
+// @Produce json
+// @Param ids body []int true "IDs of the subscriptions to delete, at most 10000"
+// @Param atomic query bool false "Whether any failed item fails the whole batch (default true)"
+// @Success 200 {object} routes.BulkResult
+// @Failure 400 {object} apierror.Problem
+// @Failure 413 {object} apierror.Problem
+// @Failure 415 {object} apierror.Problem
+// @Failure 422 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/subscriptions/bulk/delete [post]
+func (h *subscriptionHandler) bulkDelete(ctx *gin.Context) {
+	atomic, raw, ok := readBulk(ctx)
+	if !ok {
+		return
+	}
+	errs := make([]*apierror.Error, len(raw))
+	ids := make([]int64, 0, len(raw))
+	indexes := make([]int, 0, len(raw))
+	for i, item := range raw {
+		var id int64
+		if json.Unmarshal(item, &id) != nil || id <= 0 {
+			errs[i] = apierror.BadRequest("id must be a positive integer")
+			continue
+		}
+		ids = append(ids, id)
+		indexes = append(indexes, i)
+	}
+	if atomic && bulkFailed(errs) {
+		apierror.Respond(ctx, bulkError(errs))
+		return
+	}
+	deleteErrs, err := h.repo.DeleteMany(ctx.Request.Context(), ids, auditInfo(ctx), atomic)
+	if err != nil {
+		apierror.Respond(ctx, apierror.Internal(err, "could not delete the subscriptions"))
+		return
+	}
+	for j, i := range indexes {
+		if deleteErrs[j] != nil {
+			errs[i] = itemError(deleteErrs[j])
+		}
+	}
+	if atomic && bulkFailed(errs) {
+		apierror.Respond(ctx, bulkError(errs))
+		return
+	}
+	result := newBulkResult(errs)
+	for j, i := range indexes {
+		if errs[i] == nil {
+			result.Items[i] = BulkItem{Index: i, Id: ids[j], Status: http.StatusOK}
+		}
+	}
+	respondBulk(ctx, atomic, http.StatusOK, result)
+}
+
+// readBulk reads the atomic parameter and the items of a bulk request, either
+// a JSON array or NDJSON. Lines of NDJSON that are not JSON are kept as they
+// are and fail as items, not as the whole request.
+func readBulk(ctx *gin.Context) (atomic bool, items []json.RawMessage, ok bool) {
+	atomic = true
+	if v := ctx.Query("atomic"); v != "" {
+		var err error
+		if atomic, err = strconv.ParseBool(v); err != nil {
+			apierror.Respond(ctx, apierror.BadRequest("atomic must be true or false"))
+			return false, nil, false
+		}
+	}
+	switch ctx.ContentType() {
+	case ndjsonType:
+		scanner := bufio.NewScanner(ctx.Request.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			items = append(items, bytes.Clone(line))
+			if len(items) > maxBulkItems {
+				break
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			apierror.Respond(ctx, apierror.BadRequest("could not read the items: "+err.Error()))
+			return false, nil, false
+		}
+	case "", binding.MIMEJSON:
+		if err := json.NewDecoder(ctx.Request.Body).Decode(&items); err != nil && err != io.EOF {
+			apierror.Respond(ctx, apierror.BadRequest("body must be a JSON array"))
+			return false, nil, false
+		}
+	default:
+		apierror.Respond(ctx, apierror.UnsupportedMediaType("Content-Type must be "+binding.MIMEJSON+" or "+ndjsonType))
+		return false, nil, false
+	}
+	if len(items) == 0 {
+		apierror.Respond(ctx, apierror.BadRequest("no items given"))
+		return false, nil, false
+	}
+	if len(items) > maxBulkItems {
+		apierror.Respond(ctx, apierror.BadRequest(fmt.Sprintf("at most %d items may be sent at once", maxBulkItems)).WithStatus(http.StatusRequestEntityTooLarge))
+		return false, nil, false
+	}
+	return atomic, items, true
+}
+
+// decodeBulkItem decodes and validates one item of a bulk request.
+func decodeBulkItem(item json.RawMessage, obj any) *apierror.Error {
+	if !json.Valid(item) {
+		return apierror.BadRequest("item is not valid JSON")
+	}
+	if err := json.Unmarshal(item, obj); err != nil {
+		return apierror.BadRequest(err.Error())
+	}
+	return helpers.Validate(obj)
+}
+
+// serviceResolver returns resolveService memoized for one request, as the
+// items of a batch usually name few distinct services.
+func (h *subscriptionHandler) serviceResolver(ctx context.Context) func(id int64, name string) (*models.Service, error) {
+	type key struct {
+		id   int64
+		name string
+	}
+	type resolved struct {
+		service *models.Service
+		err     error
+	}
+	cache := make(map[key]resolved)
+	return func(id int64, name string) (*models.Service, error) {
+		k := key{id, name}
+		if r, ok := cache[k]; ok {
+			return r.service, r.err
+		}
+		service, err := h.resolveService(ctx, id, name)
+		cache[k] = resolved{service, err}
+		return service, err
+	}
+}
+
+// itemError is the problem of an item the repository failed to change.
+func itemError(err error) *apierror.Error {
+	var verr validator.ValidationErrors
+	if err == sql.ErrNoRows {
+		return apierror.NotFound("subscription not found")
+	} else if errors.Is(err, models.ErrVersionConflict) {
+		return apierror.Conflict(err)
+	} else if errors.As(err, &verr) {
+		return helpers.ValidationError(&models.Subscription{}, verr, http.StatusUnprocessableEntity)
+	}
+	return apierror.Unprocessable(err)
+}
+
+func bulkFailed(errs []*apierror.Error) bool {
+	for _, e := range errs {
+		if e != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// bulkError is the problem of an atomic batch with failed items. The errors
+// are keyed by the index of the item, followed by the path of the field for
+// invalid fields, e.g. "[2].monthly_price.amount".
+func bulkError(errs []*apierror.Error) *apierror.Error {
+	fields := make(map[string]string)
+	failed := 0
+	for i, e := range errs {
+		if e == nil {
+			continue
+		}
+		failed++
+		if len(e.Fields) == 0 {
+			fields[fmt.Sprintf("[%d]", i)] = e.Detail
+		}
+		for field, msg := range e.Fields {
+			fields[fmt.Sprintf("[%d].%s", i, field)] = msg
+		}
+	}
+	e := apierror.Invalid(fields).WithStatus(http.StatusUnprocessableEntity)
+	e.Detail = fmt.Sprintf("%d of %d items failed, nothing was saved", failed, len(errs))
+	return e
+}
+
+// newBulkResult returns a result listing the failed items; the caller fills
+// in the others.
+func newBulkResult(errs []*apierror.Error) *BulkResult {
+	result := &BulkResult{Items: make([]BulkItem, len(errs))}
+	for i, e := range errs {
+		if e == nil {
+			result.Succeeded++
+			continue
+		}
+		p := e.Problem("")
+		result.Items[i] = BulkItem{Index: i, Status: e.Status, Error: &p}
+		result.Failed++
+	}
+	return result
+}
+
+// respondBulk responds with the result of a batch, with status when it was
+// atomic and 200 otherwise, as the items of a batch that is not atomic may
+// have different outcomes.
+func respondBulk(ctx *gin.Context, atomic bool, status int, result *BulkResult) {
+	if !atomic {
+		status = http.StatusOK
+	}
+	ctx.JSON(status, result)
+}
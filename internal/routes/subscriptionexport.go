@@ -0,0 +1,199 @@
+package routes
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/apierror"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/export"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/helpers"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/importer"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/logger"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+)
+
+var invoiceLineColumns = []string{"subscription_id", "service_id", "service_name", "user_id", "month", "amount", "currency"}
+
+// @Summary Export subscriptions
+// @Description Download all subscriptions matching the filters of the listing, sorted like it, as CSV, NDJSON or XLSX. The pagination parameters are ignored. The file is streamed as the subscriptions are read, so a failure after the first row cuts it short. CSV and XLSX have the columns id, service_id, service_name, monthly_price.amount, monthly_price.currency, user_id, start_date and end_date, so that a CSV export can be imported again
+// @Tags Subscription
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "Format of the file (default csv)" Enums(csv, ndjson, xlsx)
+// @Param user_id query string false "User ID"
+// @Param service_id query int false "Service ID"
+// @Param service_name query string false "Service name or alias"
+// @Param currency query string false "Currency of the monthly price"
+// @Param min_price query int false "Minimal monthly price in minor units"
+// @Param max_price query int false "Maximal monthly price in minor units"
+// @Param active_at query string false "Month the subscription is active in (MM-YYYY)"
+// @Param start_from query string false "Earliest start date (MM-YYYY)"
+// @Param start_to query string false "Latest start date (MM-YYYY)"
+// @Param end_from query string false "Earliest end date (MM-YYYY)"
+// @Param end_to query string false "Latest end date (MM-YYYY)"
+// @Param sort_by query string false "Sort column" Enums(id, service_name, monthly_price, start_date, end_date)
+// @Param order query string false "Sort direction" Enums(asc, desc)
+// @Param include_deleted query bool false "Whether deleted subscriptions are exported too"
+// @Success 200 {file} file
+// @Failure 400 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/subscriptions/export [get]
+func (h *subscriptionHandler) exportSubscriptions(ctx *gin.Context) {
+	format, ok := exportFormat(ctx)
+	if !ok {
+		return
+	}
+	var filter models.SubscriptionFilter
+	if !helpers.BindQueryWithValidation(ctx, &filter) {
+		return
+	}
+	filter.SetDefaults()
+	found, err := h.resolveServiceFilter(ctx.Request.Context(), &filter.ServiceId, filter.ServiceName)
+	if err != nil {
+		apierror.Respond(ctx, apierror.Internal(err, "could not resolve the service"))
+		return
+	}
+	out := &exportStream{ctx: ctx, format: format, name: "subscriptions", columns: importer.CSVColumns}
+	if found {
+		err = h.repo.EachSubscription(ctx.Request.Context(), &filter, func(s models.Subscription) error {
+			return out.write(s, subscriptionCells(s))
+		})
+	}
+	if err != nil {
+		out.fail(apierror.Internal(err, "could not export the subscriptions"))
+		return
+	}
+	out.close()
+}
+
+// @Summary Export invoice breakdown
+// @Description Download the months billed for the invoice, one line per subscription and month, as CSV, NDJSON or XLSX. With a currency the amounts are converted into it, each at the rate of its month. The file is streamed as the months are read, so a failure after the first line cuts it short. CSV and XLSX have the columns subscription_id, service_id, service_name, user_id, month, amount and currency
+// @Tags Subscription
+// @Accept json
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "Format of the file (default csv)" Enums(csv, ndjson, xlsx)
+// @Param request body models.SubscriptionInvoiceRequest true "Invoice Request"
+// @Success 200 {array} models.InvoiceLine
+// @Failure 400 {object} apierror.Problem
+// @Failure 422 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/invoices/export [post]
+func (h *subscriptionHandler) exportInvoice(ctx *gin.Context) {
+	format, ok := exportFormat(ctx)
+	if !ok {
+		return
+	}
+	var request models.SubscriptionInvoiceRequest
+	if !helpers.BindJSONWithValidation(ctx, &request) {
+		return
+	}
+	found, err := h.resolveServiceFilter(ctx.Request.Context(), &request.ServiceId, request.ServiceName)
+	if err != nil {
+		apierror.Respond(ctx, apierror.Internal(err, "could not resolve the service"))
+		return
+	}
+	out := &exportStream{ctx: ctx, format: format, name: "invoice", columns: invoiceLineColumns}
+	if found {
+		// each rate is looked up once rather than for every line read from the cursor
+		converter := h.converter.WithCache()
+		err = h.repo.EachInvoiceLine(ctx.Request.Context(), &request, func(l models.InvoiceLine) error {
+			amount, err := models.ConvertMoney(ctx.Request.Context(), l.Amount, request.Currency, l.Month, converter)
+			if err != nil {
+				return err
+			}
+			l.Amount = amount
+			return out.write(l, invoiceLineCells(l))
+		})
+	}
+	if err != nil {
+		out.fail(invoiceProblem(err, "could not export the invoice"))
+		return
+	}
+	out.close()
+}
+
+func exportFormat(ctx *gin.Context) (export.Format, bool) {
+	format, err := export.ParseFormat(ctx.Query("format"))
+	if err != nil {
+		apierror.Respond(ctx, apierror.BadRequest(err.Error()))
+		return "", false
+	}
+	return format, true
+}
+
+func subscriptionCells(s models.Subscription) []any {
+	var end any
+	if s.EndDate != nil {
+		end = s.EndDate.String()
+	}
+	return []any{s.Id, s.ServiceId, s.ServiceName, s.MonthlyPrice.Amount, s.MonthlyPrice.Currency,
+		s.UserId.String(), s.StartDate.String(), end}
+}
+
+func invoiceLineCells(l models.InvoiceLine) []any {
+	return []any{l.SubscriptionId, l.ServiceId, l.ServiceName, l.UserId.String(), l.Month.String(),
+		l.Amount.Amount, l.Amount.Currency}
+}
+
+// exportStream writes an export to the response. The response starts with the
+// first row, so that a failure before any output is still answered with a
+// problem.
+type exportStream struct {
+	ctx     *gin.Context
+	format  export.Format
+	name    string
+	columns []string
+	writer  export.Writer
+}
+
+func (s *exportStream) write(v any, cells []any) error {
+	if s.writer == nil {
+		if err := s.start(); err != nil {
+			return err
+		}
+	}
+	return s.writer.Write(v, cells)
+}
+
+func (s *exportStream) start() error {
+	s.ctx.Header("Content-Type", s.format.ContentType())
+	s.ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, s.name, s.format))
+	s.ctx.Status(http.StatusOK)
+	w, err := export.NewWriter(s.ctx.Writer, s.format, s.columns)
+	if err != nil {
+		return err
+	}
+	s.writer = w
+	return nil
+}
+
+// close completes the export, which has only a header when there were no rows.
+func (s *exportStream) close() {
+	if s.writer == nil {
+		if err := s.start(); err != nil {
+			s.fail(apierror.Internal(err, "could not start the export"))
+			return
+		}
+	}
+	if err := s.writer.Close(); err != nil {
+		s.fail(apierror.Internal(err, "could not complete the export"))
+	}
+}
+
+// fail responds with e while nothing of the export has been sent. Afterwards
+// the status has been sent, so the export is only cut short.
+func (s *exportStream) fail(e *apierror.Error) {
+	if !s.ctx.Writer.Written() {
+		s.ctx.Writer.Header().Del("Content-Disposition")
+		apierror.Respond(s.ctx, e)
+		return
+	}
+	logger.Log.Error("export failed", slog.String("path", s.ctx.Request.URL.Path),
+		slog.String("request_id", s.ctx.GetString(requestIdKey)), slog.Any("err", e.Err))
+	s.ctx.Abort()
+}
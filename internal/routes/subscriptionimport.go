@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/apierror"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/importer"
+)
+
+const csvType = "text/csv"
+
+// @Summary Import subscriptions
+// @Description Create subscriptions from CSV or NDJSON, sent as the request body or as the "file" field of a multipart form. NDJSON holds one subscription per line in its JSON shape; CSV has a header naming its columns among id, service_id, service_name, monthly_price.amount, monthly_price.currency, user_id, start_date and end_date, dates formatted as MM-YYYY. Every row is validated like a new subscription. Invalid rows and rows duplicating a stored subscription or an earlier row (same user, service, monthly price and dates) are skipped and listed in the report, the others are saved in batches of 1000
+// @Tags Subscription
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file false "CSV or NDJSON file"
+// @Param format query string false "Format of the file, told by the Content-Type or the file extension by default" Enums(csv, ndjson)
+// @Param dry_run query bool false "Whether the rows are only checked and nothing is saved"
+// @Success 200 {object} importer.Report
+// @Failure 400 {object} apierror.Problem
+// @Failure 415 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/subscriptions/import [post]
+func (h *subscriptionHandler) importSubscriptions(ctx *gin.Context) {
+	dryRun := false
+	if v := ctx.Query("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			apierror.Respond(ctx, apierror.BadRequest("dry_run must be true or false"))
+			return
+		}
+	}
+	body, format, ok := importFile(ctx)
+	if !ok {
+		return
+	}
+	defer body.Close()
+	rows, err := importer.NewReader(body, format)
+	if err != nil {
+		apierror.Respond(ctx, apierror.BadRequest(err.Error()))
+		return
+	}
+	report, err := importer.New(h.repo, h.services).Import(ctx.Request.Context(), rows, importer.Options{DryRun: dryRun, Audit: auditInfo(ctx)})
+	if errors.Is(err, importer.ErrUnreadable) {
+		apierror.Respond(ctx, apierror.BadRequest(err.Error()))
+		return
+	} else if err != nil {
+		apierror.Respond(ctx, apierror.Internal(err, "could not import the subscriptions"))
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
+
+// importFile returns the file of an import request and its format, given by
+// the format parameter or else by the Content-Type of the body or the
+// extension of the uploaded file.
+func importFile(ctx *gin.Context) (io.ReadCloser, importer.Format, bool) {
+	var format importer.Format
+	if v := ctx.Query("format"); v != "" {
+		var err error
+		if format, err = importer.ParseFormat(v); err != nil {
+			apierror.Respond(ctx, apierror.BadRequest(err.Error()))
+			return nil, "", false
+		}
+	}
+	contentType := ctx.ContentType()
+	if strings.HasPrefix(contentType, "multipart/") {
+		file, err := ctx.FormFile("file")
+		if err != nil {
+			apierror.Respond(ctx, apierror.BadRequest("the file field must hold a CSV or NDJSON file"))
+			return nil, "", false
+		}
+		if format == "" {
+			if format, err = importer.FormatOf(file.Filename); err != nil {
+				apierror.Respond(ctx, apierror.BadRequest(err.Error()))
+				return nil, "", false
+			}
+		}
+		f, err := file.Open()
+		if err != nil {
+			apierror.Respond(ctx, apierror.BadRequest("could not read the uploaded file"))
+			return nil, "", false
+		}
+		return f, format, true
+	}
+	if format == "" {
+		switch contentType {
+		case csvType:
+			format = importer.FormatCSV
+		case ndjsonType:
+			format = importer.FormatNDJSON
+		default:
+			apierror.Respond(ctx, apierror.UnsupportedMediaType("Content-Type must be "+csvType+", "+ndjsonType+" or multipart/form-data"))
+			return nil, "", false
+		}
+	}
+	return ctx.Request.Body, format, true
+}
@@ -1,33 +1,101 @@
 package routes
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/apierror"
 	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/helpers"
 	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
 )
 
 // getSubscriptionsInvoice calculates the invoice for subscriptions
 // @Summary Get subscriptions invoice
-// @Description Calculate the total cost of subscriptions for a given user and period
+// @Description Calculate the total cost of subscriptions for a period, optionally filtered by user and service and grouped by service, user or month
 // @Tags Subscription
 // @Accept json
 // @Produce json
 // @Param request body models.SubscriptionInvoiceRequest true "Invoice Request"
-// @Success 200 {object} map[string]int32
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /subscription/invoice [post]
-func getSubscriptionsInvoice(ctx *gin.Context) {
+// @Success 200 {object} models.SubscriptionInvoice
+// @Failure 400 {object} apierror.Problem
+// @Failure 422 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/invoices [post]
+func (h *subscriptionHandler) getSubscriptionsInvoice(ctx *gin.Context) {
 	var request models.SubscriptionInvoiceRequest
 	if !helpers.BindJSONWithValidation(ctx, &request) {
 		return
 	}
-	invoice, err := request.GetSubscriptionsInvoice()
+	found, err := h.resolveServiceFilter(ctx.Request.Context(), &request.ServiceId, request.ServiceName)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"message": "Could not fetch subscriptions invoice"})
+		apierror.Respond(ctx, apierror.Internal(err, "could not resolve the service"))
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"sum": invoice})
+	var amounts []models.InvoiceAmount
+	if found {
+		amounts, err = h.repo.GetSubscriptionsInvoice(ctx.Request.Context(), &request)
+		if err != nil {
+			apierror.Respond(ctx, apierror.Internal(err, "could not calculate the invoice"))
+			return
+		}
+	}
+	invoice, err := models.NewSubscriptionInvoice(ctx.Request.Context(), amounts, &request, h.converter.WithCache())
+	if err != nil {
+		invoiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, invoice)
+}
+
+// getSubscriptionsInvoiceDetails calculates the invoice itemised per subscription and month
+// @Summary Get subscriptions invoice details
+// @Description Calculate the cost of subscriptions for a period, broken down per subscription and calendar month
+// @Tags Subscription
+// @Accept json
+// @Produce json
+// @Param request body models.SubscriptionInvoiceRequest true "Invoice Request"
+// @Success 200 {object} models.SubscriptionInvoiceDetails
+// @Failure 400 {object} apierror.Problem
+// @Failure 422 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/invoices/details [post]
+func (h *subscriptionHandler) getSubscriptionsInvoiceDetails(ctx *gin.Context) {
+	var request models.SubscriptionInvoiceRequest
+	if !helpers.BindJSONWithValidation(ctx, &request) {
+		return
+	}
+	found, err := h.resolveServiceFilter(ctx.Request.Context(), &request.ServiceId, request.ServiceName)
+	if err != nil {
+		apierror.Respond(ctx, apierror.Internal(err, "could not resolve the service"))
+		return
+	}
+	details := &models.SubscriptionInvoiceDetails{Subscriptions: []models.SubscriptionInvoiceItem{}}
+	if found {
+		details, err = h.repo.GetSubscriptionsInvoiceDetails(ctx.Request.Context(), &request)
+		if err != nil {
+			apierror.Respond(ctx, apierror.Internal(err, "could not calculate the invoice details"))
+			return
+		}
+	}
+	if err := details.Total(ctx.Request.Context(), request.Currency, h.converter.WithCache()); err != nil {
+		invoiceError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, details)
+}
+
+// invoiceError reports a failure to total an invoice: currency problems are the
+// client's to fix, anything else is a storage failure.
+func invoiceError(ctx *gin.Context, err error) {
+	apierror.Respond(ctx, invoiceProblem(err, "could not convert the invoice"))
+}
+
+// invoiceProblem is the problem invoiceError responds with, with detail
+// describing a storage failure.
+func invoiceProblem(err error, detail string) *apierror.Error {
+	if errors.Is(err, models.ErrCurrencyMismatch) || errors.Is(err, models.ErrConversionUnavailable) {
+		return apierror.Unprocessable(err)
+	}
+	return apierror.Internal(err, detail)
 }
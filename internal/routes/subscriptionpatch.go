@@ -0,0 +1,135 @@
+package routes
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/apierror"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/helpers"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/jsonpatch"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+)
+
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// errInvalidDocument wraps the errors of a patched document that cannot be saved.
+var errInvalidDocument = errors.New("patched subscription is invalid")
+
+// @Summary Patch subscription
+// @Description Change fields of a subscription with a JSON Merge Patch (RFC 7396, application/merge-patch+json) or a JSON Patch (RFC 6902, application/json-patch+json) applied to models.SubscriptionDocument. Unlike PUT, null and zero values are applied: a null end_date makes the subscription open-ended. The patched subscription is validated before it is saved
+// @Tags Subscription
+// @Accept application/merge-patch+json
+// @Accept application/json-patch+json
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Param patch body object true "Merge patch object or array of JSON Patch operations"
+// @Param price_effective_from query string false "Month a new monthly price takes effect from (MM-YYYY), the current month by default"
+// @Param If-Match header string false "ETag of the version the patch is based on"
+// @Success 200 {object} models.Subscription
+// @Header 200 {string} ETag "Version of the patched subscription"
+// @Failure 400 {object} apierror.Problem
+// @Failure 404 {object} apierror.Problem
+// @Failure 409 {object} apierror.Problem
+// @Failure 415 {object} apierror.Problem
+// @Failure 422 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/subscriptions/{id} [patch]
+func (h *subscriptionHandler) patch(ctx *gin.Context) {
+	id, ok := idParam(ctx)
+	if !ok {
+		return
+	}
+	var applyPatch func(doc, patch []byte) ([]byte, error)
+	switch ctx.ContentType() {
+	case mergePatchType:
+		applyPatch = jsonpatch.MergePatch
+	case jsonPatchType:
+		applyPatch = jsonpatch.Apply
+	default:
+		apierror.Respond(ctx, apierror.UnsupportedMediaType("Content-Type must be "+mergePatchType+" or "+jsonPatchType))
+		return
+	}
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		apierror.Respond(ctx, apierror.BadRequest("could not read the patch"))
+		return
+	}
+	var effective struct {
+		PriceEffectiveFrom *models.MonthYear `form:"price_effective_from"`
+	}
+	if !helpers.BindQueryWithValidation(ctx, &effective) {
+		return
+	}
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		apierror.Respond(ctx, errInvalidIfMatch)
+		return
+	}
+
+	var doc models.SubscriptionDocument
+	patch := &models.SubscriptionPatch{
+		Version:            version,
+		PriceEffectiveFrom: effective.PriceEffectiveFrom,
+		Apply: func(s *models.Subscription) error {
+			current, err := json.Marshal(models.NewSubscriptionDocument(s))
+			if err != nil {
+				return err
+			}
+			patched, err := applyPatch(current, body)
+			if err != nil {
+				return err
+			}
+			dec := json.NewDecoder(bytes.NewReader(patched))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&doc); err != nil {
+				return fmt.Errorf("%w: %v", errInvalidDocument, err)
+			}
+			if err := binding.Validator.ValidateStruct(&doc); err != nil {
+				return fmt.Errorf("%w: %w", errInvalidDocument, err)
+			}
+			// a renamed service is looked up by its new name, not by the old id
+			if doc.ServiceName != "" && doc.ServiceName != s.ServiceName {
+				doc.ServiceId = 0
+			}
+			service, err := h.resolveService(ctx.Request.Context(), doc.ServiceId, doc.ServiceName)
+			if err != nil {
+				return err
+			}
+			doc.ServiceId, doc.ServiceName = service.Id, service.Name
+			doc.ApplyTo(s)
+			return nil
+		},
+	}
+	updated, err := h.repo.Patch(ctx.Request.Context(), id, patch, auditInfo(ctx))
+	var verr validator.ValidationErrors
+	switch {
+	case err == nil:
+		ctx.Header("ETag", etag(updated.Version))
+		ctx.JSON(http.StatusOK, updated)
+	case err == sql.ErrNoRows:
+		apierror.Respond(ctx, apierror.NotFound("subscription not found"))
+	case errors.Is(err, models.ErrVersionConflict), errors.Is(err, jsonpatch.ErrTestFailed):
+		apierror.Respond(ctx, apierror.Conflict(err))
+	case errors.Is(err, jsonpatch.ErrInvalidPatch):
+		apierror.Respond(ctx, apierror.BadRequest(err.Error()))
+	case errors.As(err, &verr):
+		helpers.RespondInvalid(ctx, &doc, verr, http.StatusUnprocessableEntity)
+	case errors.Is(err, errInvalidDocument), errors.Is(err, jsonpatch.ErrPatchNotApplicable):
+		apierror.Respond(ctx, apierror.Unprocessable(err))
+	case errors.Is(err, models.ErrUnknownService):
+		serviceError(ctx, err)
+	default:
+		apierror.Respond(ctx, apierror.Internal(err, "could not patch the subscription"))
+	}
+}
@@ -0,0 +1,81 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+)
+
+func TestPatchSubscription(t *testing.T) {
+	server := newTestServer(t)
+	expect(t, serve(server, http.MethodPost, "/api/v1/subscriptions",
+		`{"service_name":"Netflix","monthly_price":{"amount":39900,"currency":"RUB"},"user_id":"`+testUserId+`","start_date":"01-2025","end_date":"12-2025"}`),
+		http.StatusCreated, nil)
+	path := "/api/v1/subscriptions/1"
+
+	tests := []struct {
+		name        string
+		contentType string
+		patch       string
+		status      int
+		// price and end date of the subscription after the patch, "" for none
+		amount  int64
+		endDate string
+	}{
+		{
+			name:        "absent end_date is left as is",
+			contentType: mergePatchType, patch: `{"monthly_price":{"amount":49900}}`,
+			status: http.StatusOK, amount: 49900, endDate: "12-2025",
+		},
+		{
+			name:        "null end_date makes it open-ended",
+			contentType: mergePatchType, patch: `{"end_date":null}`,
+			status: http.StatusOK, amount: 49900,
+		},
+		{
+			name:        "zero price is rejected after the merge",
+			contentType: mergePatchType, patch: `{"monthly_price":{"amount":0}}`,
+			status: http.StatusUnprocessableEntity, amount: 49900,
+		},
+		{
+			name:        "zero price is rejected after a JSON Patch",
+			contentType: jsonPatchType, patch: `[{"op":"replace","path":"/monthly_price/amount","value":0}]`,
+			status: http.StatusUnprocessableEntity, amount: 49900,
+		},
+		{
+			name:        "JSON Patch sets the end date",
+			contentType: jsonPatchType, patch: `[{"op":"test","path":"/end_date","value":null},{"op":"replace","path":"/end_date","value":"06-2026"}]`,
+			status: http.StatusOK, amount: 49900, endDate: "06-2026",
+		},
+		{
+			name:        "failing test operation",
+			contentType: jsonPatchType, patch: `[{"op":"test","path":"/end_date","value":null},{"op":"remove","path":"/end_date"}]`,
+			status: http.StatusConflict, amount: 49900, endDate: "06-2026",
+		},
+		{
+			name:        "unknown field",
+			contentType: mergePatchType, patch: `{"price":1}`,
+			status: http.StatusUnprocessableEntity, amount: 49900, endDate: "06-2026",
+		},
+		{
+			name:        "unsupported content type",
+			contentType: "application/json", patch: `{"end_date":null}`,
+			status: http.StatusUnsupportedMediaType, amount: 49900, endDate: "06-2026",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, serveContent(server, http.MethodPatch, path, tt.contentType, tt.patch), tt.status, nil)
+			var got models.Subscription
+			expect(t, serve(server, http.MethodGet, path, ""), http.StatusOK, &got)
+			var endDate string
+			if got.EndDate != nil {
+				endDate = got.EndDate.ToTime().Format("01-2006")
+			}
+			if got.MonthlyPrice.Amount != tt.amount || endDate != tt.endDate {
+				t.Errorf("got price %d and end date %q, want %d and %q", got.MonthlyPrice.Amount, endDate, tt.amount, tt.endDate)
+			}
+		})
+	}
+}
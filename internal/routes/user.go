@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/apierror"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+)
+
+// @Summary Get user subscriptions
+// @Description Get all subscriptions of a user, split into active and historical ones
+// @Tags User
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Success 200 {object} models.UserSubscriptions
+// @Failure 400 {object} apierror.Problem
+// @Failure 500 {object} apierror.Problem
+// @Router /api/v1/users/{user_id}/subscriptions [get]
+func (h *subscriptionHandler) getUserSubscriptions(ctx *gin.Context) {
+	userId, err := uuid.Parse(ctx.Param("user_id"))
+	if err != nil {
+		apierror.Respond(ctx, apierror.BadRequest("user_id must be a UUID"))
+		return
+	}
+	subscriptions, err := h.repo.GetByUserId(ctx.Request.Context(), userId)
+	if err != nil {
+		apierror.Respond(ctx, apierror.Internal(err, "could not fetch the subscriptions of the user"))
+		return
+	}
+	ctx.JSON(http.StatusOK, models.NewUserSubscriptions(userId, subscriptions, time.Now()))
+}
@@ -1,13 +1,15 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
 
-	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
 	_ "github.com/lib/pq"
 	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/config"
 	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/logger"
@@ -15,34 +17,88 @@ import (
 
 var DB *sql.DB
 
-func InitDB(cfg *config.Config) {
+// The delay between connection attempts doubles from minBackoff up to
+// maxBackoff. Each attempt may take up to pingTimeout.
+const (
+	minBackoff  = 100 * time.Millisecond
+	maxBackoff  = 5 * time.Second
+	pingTimeout = 5 * time.Second
+)
+
+// InitDB connects to the database, waiting for it for up to cfg.ConnectTimeout,
+// and applies the migrations unless cfg.AutoMigrate is off. DB is set only when
+// both succeed.
+func InitDB(cfg *config.Config) error {
 	logger.Log.Info("starting db connection")
-	dbParams := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Name,
-	)
-	db, err := sql.Open("postgres", dbParams)
+	db, err := sql.Open("postgres", dsn(cfg.DBConfig))
 	if err != nil {
-		logger.Log.Error("could not connect to db", slog.Any("err", err))
-		panic("could not connect to db")
+		return fmt.Errorf("could not open the db: %w", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	if err := connect(db, cfg.ConnectTimeout); err != nil {
+		db.Close()
+		return err
 	}
-	runMigrations(cfg)
-	DB = db
 	logger.Log.Info("db connected successfully")
+	if !cfg.AutoMigrate {
+		logger.Log.Info("automatic migrations disabled")
+	} else if err := runMigrations(cfg); err != nil {
+		db.Close()
+		return err
+	}
+	DB = db
+	return nil
 }
 
-func runMigrations(cfg *config.Config) {
-	logger.Log.Info("starting migrations")
-	dsn := fmt.Sprintf(
-		"postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Name,
-	)
-	m, err := migrate.New("file://migrations", dsn)
-	if err != nil {
-		logger.Log.Error("migration setup error", slog.Any("err", err))
+// connect pings the database until it answers, as sql.Open does not connect.
+// It gives up when it still fails once timeout has passed.
+func connect(db *sql.DB, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	backoff := minBackoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		err := db.PingContext(ctx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		wait := min(backoff, time.Until(deadline))
+		if wait <= 0 {
+			return fmt.Errorf("db not reachable within %s after %d attempts: %w", timeout, attempt, err)
+		}
+		logger.Log.Warn("db not reachable yet", slog.Int("attempt", attempt),
+			slog.Duration("retry_in", wait), slog.Any("err", err))
+		time.Sleep(wait)
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// dsn is the connection URL of the database, shared by the driver and the
+// migrations. Options left empty are left to the driver defaults.
+func dsn(cfg config.DBConfig) string {
+	params := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+	set("sslmode", cfg.SSLMode)
+	set("sslrootcert", cfg.SSLRootCert)
+	set("sslcert", cfg.SSLCert)
+	set("sslkey", cfg.SSLKey)
+	set("application_name", cfg.ApplicationName)
+	if cfg.StatementTimeout > 0 {
+		set("statement_timeout", strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10))
 	}
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		logger.Log.Error("migration failed", slog.Any("err", err))
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     net.JoinHostPort(cfg.Host, cfg.Port),
+		Path:     cfg.Name,
+		RawQuery: params.Encode(),
 	}
-	logger.Log.Info("migrations applied successfully")
+	return u.String()
 }
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/config"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/logger"
+)
+
+// migrationsURL is where the migrations are read from. A relative
+// MigrationsPath is relative to the working directory.
+func migrationsURL(cfg config.DBConfig) string {
+	return "file://" + filepath.ToSlash(cfg.MigrationsPath)
+}
+
+// NewMigrate returns a migrator of the database with the migrations shipped
+// with the service. It must be closed after use.
+func NewMigrate(cfg *config.Config) (*migrate.Migrate, error) {
+	m, err := migrate.New(migrationsURL(cfg.DBConfig), dsn(cfg.DBConfig))
+	if err != nil {
+		return nil, fmt.Errorf("could not set up the migrations: %w", err)
+	}
+	return m, nil
+}
+
+func runMigrations(cfg *config.Config) error {
+	logger.Log.Info("starting migrations")
+	m, err := NewMigrate(cfg)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("could not apply the migrations: %w", err)
+	}
+	logger.Log.Info("migrations applied successfully")
+	return nil
+}
+
+// LatestMigration returns the version of the last migration shipped with the
+// service, the schema version it expects the database at.
+func LatestMigration(cfg *config.Config) (uint, error) {
+	src, err := source.Open(migrationsURL(cfg.DBConfig))
+	if err != nil {
+		return 0, fmt.Errorf("could not open the migrations: %w", err)
+	}
+	defer src.Close()
+	version, err := src.First()
+	if err != nil {
+		return 0, fmt.Errorf("could not read the migrations: %w", err)
+	}
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("could not read the migrations: %w", err)
+		}
+		version = next
+	}
+}
@@ -0,0 +1,30 @@
+package validators
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+)
+
+// invoiceRules checks that the invoice window is not reversed and spans at
+// most models.MaxInvoiceMonths months. The months are counted over the window
+// the invoice queries bill, from Period: to_date is exclusive and an open
+// to_date runs through the current month.
+func invoiceRules(sl validator.StructLevel) {
+	r := sl.Current().Interface().(models.SubscriptionInvoiceRequest)
+	from, to, _ := r.Period(time.Now())
+	field := "FromDate"
+	if r.ToDate != nil {
+		field = "ToDate"
+		if to.Before(from) {
+			sl.ReportError(*r.ToDate, "ToDate", "ToDate", "gtefield", "from_date")
+			return
+		}
+	}
+	months := (to.Year()-from.Year())*12 + int(to.Month()-from.Month())
+	if months > models.MaxInvoiceMonths {
+		sl.ReportError(r.FromDate, field, field, "maxmonths", strconv.Itoa(models.MaxInvoiceMonths))
+	}
+}
@@ -0,0 +1,44 @@
+package validators
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/mukashev-n/online-subscriptions-data-aggregator-service/internal/models"
+)
+
+func TestInvoiceWindow(t *testing.T) {
+	v := validator.New()
+	RegisterValidators(v)
+	month := func(year int, m time.Month) *models.MonthYear {
+		my := models.FromTime(time.Date(year, m, 1, 0, 0, 0, 0, time.UTC))
+		return &my
+	}
+	thisMonth := models.StartOfMonth(time.Now())
+	monthsAgo := func(n int) *models.MonthYear {
+		my := models.FromTime(thisMonth.AddDate(0, -n, 0))
+		return &my
+	}
+
+	tests := []struct {
+		name     string
+		from, to *models.MonthYear
+		valid    bool
+	}{
+		{"exactly MaxInvoiceMonths", month(2016, time.January), month(2026, time.January), true},
+		{"one month over", month(2016, time.January), month(2026, time.February), false},
+		{"empty window", month(2026, time.January), month(2026, time.January), true},
+		{"reversed window", month(2026, time.February), month(2026, time.January), false},
+		{"open, through the current month", monthsAgo(models.MaxInvoiceMonths - 1), nil, true},
+		{"open, one month over", monthsAgo(models.MaxInvoiceMonths), nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Struct(models.SubscriptionInvoiceRequest{FromDate: *tt.from, ToDate: tt.to})
+			if (err == nil) != tt.valid {
+				t.Errorf("valid = %v, want %v: %v", err == nil, tt.valid, err)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package validators
 
 import (
+	"reflect"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
@@ -9,6 +10,11 @@ import (
 )
 
 func RegisterValidators(v *validator.Validate) {
+	// rates are checked by their value, like the numbers they are written as
+	v.RegisterCustomTypeFunc(func(field reflect.Value) any {
+		return field.Interface().(models.Rate).Float64()
+	}, models.Rate{})
+
 	v.RegisterValidation("monthyear", func(fl validator.FieldLevel) bool {
 		m, ok := fl.Field().Interface().(models.MonthYear)
 		if !ok {
@@ -21,12 +27,41 @@ func RegisterValidators(v *validator.Validate) {
 	})
 
 	v.RegisterValidation("uuid", func(fl validator.FieldLevel) bool {
-		id, ok := fl.Field().Interface().(uuid.UUID)
-		return ok && id.String() != ""
+		switch id := fl.Field().Interface().(type) {
+		case uuid.UUID:
+			return id != uuid.Nil
+		case string:
+			parsed, err := uuid.Parse(id)
+			return err == nil && parsed != uuid.Nil
+		}
+		return false
 	})
 
 	v.RegisterValidation("notblank", func(fl validator.FieldLevel) bool {
 		str, ok := fl.Field().Interface().(string)
 		return ok && strings.TrimSpace(str) != ""
 	})
+
+	v.RegisterStructValidation(subscriptionRules, models.Subscription{}, models.SubscriptionDocument{})
+	v.RegisterStructValidation(invoiceRules, models.SubscriptionInvoiceRequest{})
+}
+
+// subscriptionRules checks the fields of a subscription against each other:
+// it must be charged a positive price and must not end before it starts.
+func subscriptionRules(sl validator.StructLevel) {
+	switch s := sl.Current().Interface().(type) {
+	case models.Subscription:
+		checkSubscription(sl, s.MonthlyPrice, s.StartDate, s.EndDate)
+	case models.SubscriptionDocument:
+		checkSubscription(sl, s.MonthlyPrice, s.StartDate, s.EndDate)
+	}
+}
+
+func checkSubscription(sl validator.StructLevel, price models.Money, start models.MonthYear, end *models.MonthYear) {
+	if price.Amount <= 0 {
+		sl.ReportError(price.Amount, "MonthlyPrice.Amount", "MonthlyPrice.Amount", "gt", "0")
+	}
+	if end != nil && end.ToTime().Before(start.ToTime()) {
+		sl.ReportError(*end, "EndDate", "EndDate", "gtefield", "start_date")
+	}
 }
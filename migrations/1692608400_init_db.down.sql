@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS subscription;
+
+DROP SEQUENCE IF EXISTS subscription_seq;
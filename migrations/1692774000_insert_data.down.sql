@@ -0,0 +1,10 @@
+DELETE FROM subscription
+WHERE user_id = 'f47ac10b-58cc-4372-a567-0e02b2c3d479'
+    AND service_name = 'FCB Basic'
+    AND monthly_price = 100
+    AND (start_date, end_date) IN (
+        ('2025-01-01', '2025-03-01'),
+        ('2025-01-01', '2025-05-01'),
+        ('2025-03-01', '2025-07-01'),
+        ('2025-06-01', '2025-07-01')
+    );
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS subscription_user_id_start_date_idx;
+
+DROP INDEX IF EXISTS subscription_user_id_service_name_idx;
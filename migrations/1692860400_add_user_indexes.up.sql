@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS subscription_user_id_start_date_idx ON subscription (user_id, start_date);
+
+CREATE INDEX IF NOT EXISTS subscription_user_id_service_name_idx ON subscription (user_id, service_name);
@@ -0,0 +1,2 @@
+-- fails while there are open-ended subscriptions, which have to be ended first
+ALTER TABLE subscription ALTER COLUMN end_date SET NOT NULL;
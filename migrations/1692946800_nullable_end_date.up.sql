@@ -0,0 +1 @@
+ALTER TABLE subscription ALTER COLUMN end_date DROP NOT NULL;
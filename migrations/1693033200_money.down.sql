@@ -0,0 +1,6 @@
+ALTER TABLE subscription DROP COLUMN IF EXISTS currency;
+
+-- back to whole rubles; prices in other currencies are taken for rubles
+UPDATE subscription SET monthly_price = monthly_price / 100;
+
+ALTER TABLE subscription ALTER COLUMN monthly_price TYPE INT;
@@ -0,0 +1,6 @@
+ALTER TABLE subscription ALTER COLUMN monthly_price TYPE BIGINT;
+
+-- monthly_price used to hold whole rubles, it now holds minor units
+UPDATE subscription SET monthly_price = monthly_price * 100;
+
+ALTER TABLE subscription ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'RUB';
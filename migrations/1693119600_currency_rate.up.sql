@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS currency_rate (
+    base_currency CHAR(3) NOT NULL,
+    quote_currency CHAR(3) NOT NULL,
+    month DATE NOT NULL,
+    rate NUMERIC(20, 10) NOT NULL CHECK (rate > 0),
+    PRIMARY KEY (base_currency, quote_currency, month)
+);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS subscription_service_id_idx;
+
+-- service_name keeps the catalogue name of the service
+ALTER TABLE subscription DROP COLUMN IF EXISTS service_id;
+
+DROP TABLE IF EXISTS service;
+
+DROP SEQUENCE IF EXISTS service_seq;
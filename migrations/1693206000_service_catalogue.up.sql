@@ -0,0 +1,33 @@
+CREATE SEQUENCE IF NOT EXISTS service_seq START 1;
+
+CREATE TABLE IF NOT EXISTS service (
+    id BIGINT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    aliases TEXT[] NOT NULL DEFAULT '{}',
+    default_price BIGINT,
+    default_currency CHAR(3),
+    category VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS service_lower_name_idx ON service (lower(name));
+
+-- one service per distinct name, ignoring case and extra whitespace
+INSERT INTO service (id, name)
+SELECT nextval('service_seq'), name
+FROM (
+    SELECT DISTINCT ON (lower(regexp_replace(btrim(service_name), '\s+', ' ', 'g')))
+        regexp_replace(btrim(service_name), '\s+', ' ', 'g') AS name
+    FROM subscription
+    ORDER BY lower(regexp_replace(btrim(service_name), '\s+', ' ', 'g')), service_name
+) names;
+
+ALTER TABLE subscription ADD COLUMN IF NOT EXISTS service_id BIGINT REFERENCES service (id);
+
+UPDATE subscription
+SET service_id = service.id, service_name = service.name
+FROM service
+WHERE lower(service.name) = lower(regexp_replace(btrim(subscription.service_name), '\s+', ' ', 'g'));
+
+ALTER TABLE subscription ALTER COLUMN service_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS subscription_service_id_idx ON subscription (service_id);
@@ -0,0 +1,2 @@
+-- subscription.monthly_price already holds the latest price
+DROP TABLE IF EXISTS subscription_price_history;
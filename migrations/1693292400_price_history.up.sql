@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS subscription_price_history (
+    subscription_id BIGINT NOT NULL REFERENCES subscription (id) ON DELETE CASCADE,
+    effective_from DATE NOT NULL,
+    monthly_price BIGINT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    PRIMARY KEY (subscription_id, effective_from)
+);
+
+-- every existing subscription has been charged its current price since it started
+INSERT INTO subscription_price_history (subscription_id, effective_from, monthly_price, currency)
+SELECT id, start_date, monthly_price, currency
+FROM subscription
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,6 @@
+-- deleted subscriptions are purged, as they would come back otherwise
+DELETE FROM subscription WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS subscription_deleted_at_idx;
+
+ALTER TABLE subscription DROP COLUMN IF EXISTS deleted_at;
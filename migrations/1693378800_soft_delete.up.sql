@@ -0,0 +1,3 @@
+ALTER TABLE subscription ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS subscription_deleted_at_idx ON subscription (deleted_at) WHERE deleted_at IS NOT NULL;
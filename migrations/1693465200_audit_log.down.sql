@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS audit_log;
+
+DROP SEQUENCE IF EXISTS audit_log_seq;
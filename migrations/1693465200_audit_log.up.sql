@@ -0,0 +1,15 @@
+CREATE SEQUENCE IF NOT EXISTS audit_log_seq START 1;
+
+-- no foreign key: the history of a subscription outlives its purge
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGINT PRIMARY KEY DEFAULT nextval('audit_log_seq'),
+    subscription_id BIGINT NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    request_id VARCHAR(255) NOT NULL DEFAULT '',
+    before JSONB,
+    after JSONB,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_subscription_id_idx ON audit_log (subscription_id, id);
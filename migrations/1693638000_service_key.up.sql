@@ -0,0 +1,25 @@
+-- every name and alias of a service is a key only one service may hold
+CREATE TABLE IF NOT EXISTS service_key (
+    key TEXT PRIMARY KEY,
+    service_id BIGINT NOT NULL REFERENCES service (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS service_key_service_id_idx ON service_key (service_id);
+
+-- names come first, so that an alias taken by another service's name is the
+-- one dropped
+INSERT INTO service_key (key, service_id)
+SELECT lower(name), id FROM service
+ON CONFLICT (key) DO NOTHING;
+
+INSERT INTO service_key (key, service_id)
+SELECT DISTINCT ON (alias) alias, id
+FROM service, unnest(aliases) AS alias
+ORDER BY alias, id
+ON CONFLICT (key) DO NOTHING;
+
+UPDATE service
+SET aliases = ARRAY(
+    SELECT alias FROM unnest(service.aliases) AS alias
+    WHERE EXISTS (SELECT 1 FROM service_key WHERE key = alias AND service_id = service.id)
+);